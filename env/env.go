@@ -2,14 +2,20 @@
 package env
 
 import (
+	"encoding/json"
 	"errors"
+	stdflag "flag"
 	"fmt"
 	"io/fs"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/flga/flagr"
+	"github.com/flga/flagr/file"
+	"github.com/flga/flagr/internal/tree"
 	"github.com/hashicorp/go-envparse"
 )
 
@@ -38,12 +44,65 @@ func DefaultMapper(splitter Splitter) Mapper {
 // LookupFunc returns the value for the given variable and whether it was found.
 type LookupFunc func(varName string) (string, bool)
 
+// Provider returns the value for the given flag, whether it was found, and any
+// error that should abort parsing.
+type Provider func(flagName string) (value string, found bool, err error)
+
+// Observer is called for every flag whose env var is present, with its value as
+// found, before any unquoting or normalization. applied reports whether the value
+// was used to set the flag.
+type Observer func(flagName, envName, value string, applied bool)
+
+// Snapshot returns a LookupFunc backed by a copy of the environment taken when
+// Snapshot is called. Changes to the environment made afterwards are not visible.
+//
+// This avoids a syscall per lookup which can be noticeable with hundreds of flags.
+func Snapshot() LookupFunc {
+	environ := os.Environ()
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if kv == "" {
+			continue
+		}
+		// on windows there are vars like "=C:=C:\", so we skip the first char when looking for the separator
+		i := strings.Index(kv[1:], "=")
+		if i < 0 {
+			continue
+		}
+		vars[kv[:i+1]] = kv[i+2:]
+	}
+
+	return func(varName string) (string, bool) {
+		v, ok := vars[varName]
+		return v, ok
+	}
+}
+
 type options struct {
 	prefix          string
 	mapper          Mapper
 	lookupFunc      LookupFunc
+	customLookup    bool
 	envFile         *string
 	envFileOptional bool
+	observer        Observer
+	skipEmpty       bool
+	nameTransform   func(string) string
+	reportUnused    bool
+	unprefixed      bool
+	json            bool
+	truthyBools     bool
+	provider        Provider
+	blobVar         string
+	blobMapper      file.Mapper
+	unquote         bool
+	indexedLists    bool
+	nameCollector   *[]string
+	reference       string
+	structPtr       any
+	structTag       string
+	structNames     map[string]string
+	dotEnvMapper    Mapper
 }
 
 type Option func(*options)
@@ -52,6 +111,7 @@ type Option func(*options)
 func WithLookupFunc(fn LookupFunc) Option {
 	return func(o *options) {
 		o.lookupFunc = fn
+		o.customLookup = true
 	}
 }
 
@@ -81,6 +141,19 @@ func WithMapper(fn Mapper) Option {
 	}
 }
 
+// WithDotEnvMapper makes the .env file given to [WithDotEnv] use fn to map flags
+// to keys, instead of the prefix, mapper and name transform used for the process
+// environment. This allows a local .env with "PORT=8080" to be used along with
+// APP_PORT in deployed environments.
+//
+// The process environment still takes precedence and it is never looked up with
+// the names returned by fn.
+func WithDotEnvMapper(fn Mapper) Option {
+	return func(o *options) {
+		o.dotEnvMapper = fn
+	}
+}
+
 // WithPrefix prefixes every flag with s before mapping it to the corresponding env var.
 // The prefix need not end in an underscore as one will be added automatically.
 func WithPrefix(s string) Option {
@@ -89,6 +162,196 @@ func WithPrefix(s string) Option {
 	}
 }
 
+// WithObserver registers fn to be called for every flag whose env var is present,
+// even if the flag has already been set by a previous source, in which case the
+// value is not applied and applied will be false.
+//
+// This is useful for auditing what the environment would have provided.
+func WithObserver(fn Observer) Option {
+	return func(o *options) {
+		o.observer = fn
+	}
+}
+
+// WithSkipEmpty makes it so that env vars that are present but empty are treated
+// as if they were not present, both in the environment and in the .env file.
+//
+// Note that this makes it impossible to intentionally set a flag to an empty
+// string from the environment.
+func WithSkipEmpty() Option {
+	return func(o *options) {
+		o.skipEmpty = true
+	}
+}
+
+// WithNameTransform applies fn to every env var name after it has been computed by the [Mapper].
+// This allows changing casing or doing substitutions without replacing the whole Mapper.
+func WithNameTransform(fn func(string) string) Option {
+	return func(o *options) {
+		o.nameTransform = fn
+	}
+}
+
+// WithReportUnused makes the parser report, trough [flagr.Set.ReportUnused], any
+// variable that does not correspond to a flag, so that [flagr.Set.ParseStrict] can
+// fail on them.
+//
+// Every key in the .env file is checked, but since the environment is shared
+// with everything else, variables in it are only checked if a prefix was given
+// with [WithPrefix]. A [LookupFunc] or [Provider] can't be enumerated, so
+// variables are not checked at all if one was given with [WithLookupFunc] or
+// [WithProvider], only the .env file is.
+func WithReportUnused() Option {
+	return func(o *options) {
+		o.reportUnused = true
+	}
+}
+
+// WithUnprefixedFallback makes the parser look up the unprefixed env var for
+// every flag whose prefixed var is not present. The source of the flag will
+// reflect which one was used.
+//
+// This eases migrating from unprefixed to prefixed env vars.
+func WithUnprefixedFallback() Option {
+	return func(o *options) {
+		o.unprefixed = true
+	}
+}
+
+// WithJSON makes the parser decode the values of repeatable (see [flagr.IsRepeatable])
+// and map flags as JSON, bypassing the splitter.
+//
+// Repeatable flags expect an array, each element is set individually.
+// Map flags expect an object, each property is set individually as "key=value",
+// in lexical order.
+//
+// Strings are used as is, any other JSON value is used verbatim, so that
+// [1, "2"] is equivalent to ["1", "2"]. Values that are not valid JSON are an error.
+func WithJSON() Option {
+	return func(o *options) {
+		o.json = true
+	}
+}
+
+// WithTruthyBools makes the parser accept "yes", "on" and "enabled" as true, and
+// "no", "off" and "disabled" as false, ignoring case, for boolean flags.
+// Other flags are not affected.
+func WithTruthyBools() Option {
+	return func(o *options) {
+		o.truthyBools = true
+	}
+}
+
+// WithProvider makes the parser get values from fn instead of the environment and
+// the .env file. fn is given flag names as is, the prefix and the env var name
+// returned by the [Mapper] are not used, but its splitter is.
+//
+// This allows sourcing values from things like parameter stores. If fn returns
+// an error, parsing stops and the error is returned.
+func WithProvider(fn Provider) Option {
+	return func(o *options) {
+		o.provider = fn
+	}
+}
+
+// WithJSONBlob makes the parser read the env var varName, if present, as a JSON
+// object and use it to set any flag that was not found in the environment, the
+// same way [file.Parse] does for config files. Flag names are mapped to paths in
+// the object with mapper, or [file.NoopMapper] if it is nil.
+//
+// The source of flags set this way reads "env-json[varName]: key".
+func WithJSONBlob(varName string, mapper file.Mapper) Option {
+	if mapper == nil {
+		mapper = file.NoopMapper
+	}
+	return func(o *options) {
+		o.blobVar = varName
+		o.blobMapper = mapper
+	}
+}
+
+// WithUnquote makes the parser remove one pair of matching single or double
+// quotes surrounding values, so that a value of "svc" (quotes included) becomes svc.
+//
+// Only a single pair is removed, and only if the value both starts and ends with
+// the same quote. Nothing inside the value is unescaped.
+func WithUnquote() Option {
+	return func(o *options) {
+		o.unquote = true
+	}
+}
+
+// WithIndexedLists makes the parser also accept values for repeatable flags (see
+// [flagr.IsRepeatable]) in indexed form, where each element has its own env var
+// suffixed with "_<n>", starting at 0: APP_HOST_0=a, APP_HOST_1=b. Indices are
+// read in order until the first one that is missing.
+//
+// The delimited form (APP_HOST=a,b, split by the mapper's splitter) keeps working,
+// but indexed vars take precedence: they are looked up first. Setting both forms
+// for the same flag is an error.
+//
+// Elements are always applied in a stable order, so the resulting slice is
+// reproducible: indexed vars in ascending numeric order (APP_HOST_2 before
+// APP_HOST_10), delimited values in the order they appear.
+func WithIndexedLists() Option {
+	return func(o *options) {
+		o.indexedLists = true
+	}
+}
+
+// WithNameCollector makes the parser append to names the env var name computed
+// for every flag, after applying the prefix, mapper and name transform, whether
+// the var is present or not and whether the flag was already set or not. If
+// [WithUnprefixedFallback] is used, the unprefixed name follows the prefixed one.
+//
+// This is meant for troubleshooting and for generating a reference of the env
+// vars a program reads. Nothing is collected when using [WithProvider].
+func WithNameCollector(names *[]string) Option {
+	return func(o *options) {
+		o.nameCollector = names
+	}
+}
+
+// WithReference makes values of the form prefix+name, such as "@flag:addr" given
+// the prefix "@flag:", resolve to the current value of the named flag, as given
+// by its String method, at the time the value is applied. Referencing an unknown
+// flag is an error.
+//
+// Flags are visited in lexicographical order, so the referenced flag should
+// already be resolved, either by the program arguments, an earlier parser or
+// an env var of a flag that sorts before the one referencing it. Otherwise the
+// value will be its default. References are not followed recursively.
+func WithReference(prefix string) Option {
+	return func(o *options) {
+		o.reference = prefix
+	}
+}
+
+// WithStructTags makes the parser name the env vars of flags backed by the
+// fields of the struct ptr points to, as defined by [flagr.StructFlag], after
+// the given tag of each field. Given the tag "env", a field tagged with
+// `env:"DATABASE_URL"` is read from DATABASE_URL, as is, without prefix or name
+// transform.
+//
+// Fields without the tag, and flags not backed by ptr, are mapped as usual.
+// It panics if ptr is not a pointer to a struct.
+func WithStructTags(ptr any, tag string) Option {
+	if rv := reflect.ValueOf(ptr); rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("env: WithStructTags requires a pointer to a struct, got %T", ptr))
+	}
+	return func(o *options) {
+		o.structPtr = ptr
+		o.structTag = tag
+	}
+}
+
+// Parse returns a [flagr.Parser] that sets the remaining flags from environment
+// variables, configured by opts.
+//
+// Besides the string source shown by [flagr.Set.PrintValues], every value records a
+// [flagr.SourceInfo] with one of the kinds "env", "envfile", "provider" or
+// "env-json". Its detail is the env var name for "env" and "envfile", the flag
+// name for "provider" and the key path within the blob for "env-json".
 func Parse(opts ...Option) flagr.Parser {
 	options := options{
 		prefix:     "",
@@ -110,40 +373,502 @@ func Parse(opts ...Option) flagr.Parser {
 			fileData = fd
 		}
 
-		return fs.VisitRemaining(func(flag *flagr.Flag) error {
-			name, splitValBy := options.mapper(options.prefix + flag.Name)
-			src := flagr.Source("env: " + name)
-			val, ok := options.lookupFunc(name)
-			if !ok {
-				val, ok = fileData[name]
-				if options.envFile != nil {
-					src = flagr.Source(fmt.Sprintf("envfile[%s]: %s", *options.envFile, name))
+		if options.structPtr != nil {
+			options.structNames = structNames(fs, options.structPtr, options.structTag)
+		}
+
+		var blob map[string]any
+		if options.blobVar != "" {
+			if val, _, ok := options.lookup(options.blobVar, fileData); ok {
+				if err := json.Unmarshal([]byte(val), &blob); err != nil {
+					return fmt.Errorf("env: invalid json in %s: %w", options.blobVar, err)
 				}
 			}
+		}
+
+		visit := fs.VisitRemaining
+		remaining := make(map[string]bool)
+		if options.observer != nil {
+			// we need to observe every flag, but only apply to the ones that were not set before
+			fs.VisitRemaining(func(flag *flagr.Flag) error {
+				remaining[flag.Name] = true
+				return nil
+			})
+			visit = fs.VisitAll
+		}
+
+		if options.reportUnused {
+			fs.ReportUnused(options.unused(fs, fileData))
+		}
+
+		if options.nameCollector != nil && options.provider == nil {
+			fs.VisitAll(func(flag *flagr.Flag) error {
+				name, _ := options.envName(options.prefix, flag.Name)
+				*options.nameCollector = append(*options.nameCollector, name)
+				if options.unprefixed && options.prefix != "" {
+					name, _ = options.envName("", flag.Name)
+					*options.nameCollector = append(*options.nameCollector, name)
+				}
+				return nil
+			})
+		}
+
+		// with a dotenv mapper the file is only looked up by its own names
+		envFileData := fileData
+		if options.dotEnvMapper != nil {
+			envFileData = nil
+		}
+
+		return visit(func(flag *flagr.Flag) error {
+			var name, val string
+			var splitValBy Splitter
+			var src flagr.SourceInfo
+			var ok bool
+			if options.provider != nil {
+				_, splitValBy = options.mapper(flag.Name)
+				v, found, err := options.provider(flag.Name)
+				if err != nil {
+					return fmt.Errorf("env: provider failed for %s: %w", flag.Name, err)
+				}
+				name, val, src, ok = flag.Name, v, flagr.SourceInfo{Kind: "provider", Detail: flag.Name}, found
+			} else {
+				name, splitValBy = options.envName(options.prefix, flag.Name)
+				if options.indexedLists && flagr.IsRepeatable(flag) {
+					vals, srcs, err := options.lookupIndexed(name, envFileData)
+					if err != nil {
+						return err
+					}
+					if len(vals) > 0 {
+						return options.setIndexed(fs, flag, name, vals, srcs, options.observer == nil || remaining[flag.Name])
+					}
+				}
+				val, src, ok = options.lookup(name, envFileData)
+				if !ok && options.unprefixed && options.prefix != "" {
+					name, splitValBy = options.envName("", flag.Name)
+					val, src, ok = options.lookup(name, envFileData)
+				}
+				if !ok && options.dotEnvMapper != nil {
+					name, splitValBy = options.dotEnvMapper(flag.Name)
+					val, src, ok = options.lookupFile(name, fileData)
+				}
+			}
+			if !ok && blob != nil {
+				return options.setFromBlob(fs, flag, blob, options.observer == nil || remaining[flag.Name])
+			}
 			if !ok {
 				return nil
 			}
 
+			raw := val
+			if options.unquote {
+				val = unquote(val)
+			}
+			if options.reference != "" && strings.HasPrefix(val, options.reference) {
+				ref := strings.TrimPrefix(val, options.reference)
+				v, ok := fs.GetString(ref)
+				if !ok {
+					return fmt.Errorf("env: %s references unknown flag %q", name, ref)
+				}
+				val = v
+			}
+			if options.truthyBools && isBool(flag) {
+				val = normalizeBool(val)
+			}
+
+			if options.observer != nil && !remaining[flag.Name] {
+				options.observer(flag.Name, name, raw, false)
+				return nil
+			}
+
 			switch {
+			case options.json && (flagr.IsRepeatable(flag) || isMap(flag)):
+				vals, err := jsonValues(val, isMap(flag))
+				if err != nil {
+					return fmt.Errorf("env: invalid json value for %s: %w", name, err)
+				}
+				for _, val := range vals {
+					if err := fs.SetWithInfo(options.source(src), src, flag.Name, val); err != nil {
+						return fmt.Errorf("env: %w", err)
+					}
+				}
+
 			case splitValBy != "":
 				for _, val := range strings.Split(val, string(splitValBy)) {
-					if err := fs.Set(src, flag.Name, val); err != nil {
+					if err := fs.SetWithInfo(options.source(src), src, flag.Name, val); err != nil {
 						return fmt.Errorf("env: %w", err)
 					}
 				}
-				return nil
 
 			default:
-				if err := fs.Set(src, flag.Name, val); err != nil {
+				if err := fs.SetWithInfo(options.source(src), src, flag.Name, val); err != nil {
 					return fmt.Errorf("env: %w", err)
 				}
 			}
 
+			if options.observer != nil {
+				options.observer(flag.Name, name, raw, true)
+			}
+
 			return nil
 		})
 	}
 }
 
+// Bind is a convenience for the most common setup, it parses args into set,
+// falling back to the environment for flags that were not given.
+//
+// It uses the following conventions: env var names are the flag names, prefixed
+// with prefix and "_" (unless prefix is empty), with every character that is
+// not an ascii letter or number replaced by "_" and uppercased, as done by
+// [DefaultMapper]. Values are read from the process environment and are not split.
+//
+// Given the prefix "app", the flag "http-addr" is read from APP_HTTP_ADDR.
+func Bind(set *flagr.Set, args []string, prefix string) error {
+	var opts []Option
+	if prefix != "" {
+		opts = append(opts, WithPrefix(prefix))
+	}
+	return set.Parse(args, Parse(opts...))
+}
+
+// BuildReverse maps the env var names produced by mapper back to the flags in
+// set they were derived from, as in env var name -> flag name. Mapper is one way,
+// this allows going from the environment to the flags, to report unknown vars or
+// to document them.
+//
+// If more than one flag maps to the same env var name, the first one in
+// lexicographical order is kept and an error naming all of them is returned
+// along with the map.
+func BuildReverse(set *flagr.Set, mapper Mapper) (map[string]string, error) {
+	ret := make(map[string]string)
+	collisions := make(map[string][]string)
+	set.VisitAll(func(flag *flagr.Flag) error {
+		name, _ := mapper(flag.Name)
+		if prev, ok := ret[name]; ok {
+			if len(collisions[name]) == 0 {
+				collisions[name] = append(collisions[name], prev)
+			}
+			collisions[name] = append(collisions[name], flag.Name)
+			return nil
+		}
+		ret[name] = flag.Name
+		return nil
+	})
+
+	if len(collisions) == 0 {
+		return ret, nil
+	}
+
+	names := make([]string, 0, len(collisions))
+	for name := range collisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s is mapped from %s", name, strings.Join(collisions[name], ", ")))
+	}
+	return ret, fmt.Errorf("env: name collision: %s", strings.Join(msgs, "; "))
+}
+
+// lookupIndexed finds the values of the indexed vars name_0, name_1, etc.
+// It is an error for name itself to be set if any indexed var is.
+func (o options) lookupIndexed(name string, fileData map[string]string) ([]string, []flagr.SourceInfo, error) {
+	var vals []string
+	var srcs []flagr.SourceInfo
+	for i := 0; ; i++ {
+		val, src, ok := o.lookup(fmt.Sprintf("%s_%d", name, i), fileData)
+		if !ok {
+			break
+		}
+		vals = append(vals, val)
+		srcs = append(srcs, src)
+	}
+
+	if len(vals) > 0 {
+		if _, _, ok := o.lookup(name, fileData); ok {
+			return nil, nil, fmt.Errorf("env: both %s and %s_0 are set, use only one form", name, name)
+		}
+	}
+	return vals, srcs, nil
+}
+
+// setIndexed sets flag to each of vals, if apply is true.
+func (o options) setIndexed(fs *flagr.Set, flag *flagr.Flag, name string, vals []string, srcs []flagr.SourceInfo, apply bool) error {
+	if o.unquote {
+		for i := range vals {
+			vals[i] = unquote(vals[i])
+		}
+	}
+
+	if !apply {
+		o.observer(flag.Name, name, strings.Join(vals, ","), false)
+		return nil
+	}
+
+	for i, val := range vals {
+		if err := fs.SetWithInfo(o.source(srcs[i]), srcs[i], flag.Name, val); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
+	}
+
+	if o.observer != nil {
+		o.observer(flag.Name, name, strings.Join(vals, ","), true)
+	}
+	return nil
+}
+
+// setFromBlob sets flag from the value found in blob, if any and if apply is true.
+func (o options) setFromBlob(fs *flagr.Set, flag *flagr.Flag, blob map[string]any, apply bool) error {
+	key := o.blobMapper(flag.Name)
+	v, ok := tree.Find(blob, key.Split())
+	if !ok {
+		return nil
+	}
+
+	var vals []string
+	if err := tree.Stringify(v, &vals); err != nil {
+		return fmt.Errorf("env: invalid value in %s for path %q: %w", o.blobVar, key, err)
+	}
+
+	// there is no env var for the value, so we report the blob var instead
+	if !apply {
+		o.observer(flag.Name, o.blobVar, strings.Join(vals, ","), false)
+		return nil
+	}
+
+	src := flagr.SourceInfo{Kind: "env-json", Detail: string(key)}
+	for _, val := range vals {
+		if err := fs.SetWithInfo(o.source(src), src, flag.Name, val); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
+	}
+
+	if o.observer != nil {
+		o.observer(flag.Name, o.blobVar, strings.Join(vals, ","), true)
+	}
+	return nil
+}
+
+// structNames maps the flags backed by the fields of ptr to the value of their tag.
+func structNames(fs *flagr.Set, ptr any, tag string) map[string]string {
+	byAddr := make(map[any]string)
+	rv := reflect.ValueOf(ptr).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, ok := rv.Type().Field(i).Tag.Lookup(tag)
+		if !ok || name == "" || name == "-" || !rv.Type().Field(i).IsExported() {
+			continue
+		}
+		byAddr[rv.Field(i).Addr().Interface()] = name
+	}
+
+	ret := make(map[string]string)
+	fs.VisitAll(func(flag *flagr.Flag) error {
+		getter, ok := flag.Value.(stdflag.Getter)
+		if !ok {
+			return nil
+		}
+		// only pointers can be compared safely, and are what StructFlag uses
+		addr := getter.Get()
+		if reflect.ValueOf(addr).Kind() != reflect.Pointer {
+			return nil
+		}
+		if name, ok := byAddr[addr]; ok {
+			ret[flag.Name] = name
+		}
+		return nil
+	})
+	return ret
+}
+
+// envName returns the env var name for the given flag and prefix, along with its splitter.
+func (o options) envName(prefix, flagName string) (string, Splitter) {
+	if name, ok := o.structNames[flagName]; ok {
+		_, splitValBy := o.mapper(prefix + flagName)
+		return name, splitValBy
+	}
+	name, splitValBy := o.mapper(prefix + flagName)
+	if o.nameTransform != nil {
+		name = o.nameTransform(name)
+	}
+	return name, splitValBy
+}
+
+// unused returns the prefixed env vars and .env keys that don't map to any flag.
+func (o options) unused(fs *flagr.Set, fileData map[string]string) []string {
+	known := make(map[string]bool)
+	fs.VisitAll(func(flag *flagr.Flag) error {
+		name, _ := o.envName(o.prefix, flag.Name)
+		known[name] = true
+		return nil
+	})
+	isKnown := func(name string) bool {
+		if known[name] {
+			return true
+		}
+		if !o.indexedLists {
+			return false
+		}
+		i := strings.LastIndexByte(name, '_')
+		if i < 0 || i == len(name)-1 || strings.Trim(name[i+1:], "0123456789") != "" {
+			return false
+		}
+		return known[name[:i]]
+	}
+
+	var ret []string
+	if o.prefix != "" && !o.customLookup && o.provider == nil {
+		prefix, _ := o.mapper(o.prefix)
+		if o.nameTransform != nil {
+			prefix = o.nameTransform(prefix)
+		}
+		var names []string
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if strings.HasPrefix(name, prefix) && !isKnown(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ret = append(ret, "env: "+name)
+		}
+	}
+
+	isKnownFile := isKnown
+	if o.dotEnvMapper != nil {
+		fileKnown := make(map[string]bool)
+		fs.VisitAll(func(flag *flagr.Flag) error {
+			name, _ := o.dotEnvMapper(flag.Name)
+			fileKnown[name] = true
+			return nil
+		})
+		isKnownFile = func(name string) bool { return fileKnown[name] }
+	}
+
+	var names []string
+	for name := range fileData {
+		if !isKnownFile(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ret = append(ret, fmt.Sprintf("envfile[%s]: %s", *o.envFile, name))
+	}
+
+	return ret
+}
+
+// lookup finds the value for the env var name, first in the environment and then in fileData.
+func (o options) lookup(name string, fileData map[string]string) (string, flagr.SourceInfo, bool) {
+	if val, ok := o.lookupFunc(name); ok && !(o.skipEmpty && val == "") {
+		return val, flagr.SourceInfo{Kind: "env", Detail: name}, true
+	}
+
+	return o.lookupFile(name, fileData)
+}
+
+// lookupFile finds the value for the .env key name in fileData.
+func (o options) lookupFile(name string, fileData map[string]string) (string, flagr.SourceInfo, bool) {
+	if val, ok := fileData[name]; ok && !(o.skipEmpty && val == "") {
+		return val, flagr.SourceInfo{Kind: "envfile", Detail: name}, true
+	}
+
+	return "", flagr.SourceInfo{}, false
+}
+
+// source returns the string form of info, as shown by PrintValues.
+func (o options) source(info flagr.SourceInfo) flagr.Source {
+	switch info.Kind {
+	case "envfile":
+		return flagr.Source(fmt.Sprintf("envfile[%s]: %s", *o.envFile, info.Detail))
+	case "env-json":
+		return flagr.Source(fmt.Sprintf("env-json[%s]: %s", o.blobVar, info.Detail))
+	default:
+		return flagr.Source(info.Kind + ": " + info.Detail)
+	}
+}
+
+// unquote removes one pair of matching surrounding quotes from val.
+func unquote(val string) string {
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+		return val[1 : len(val)-1]
+	}
+	return val
+}
+
+// isBool reports whether the flag is a boolean flag.
+func isBool(flag *flagr.Flag) bool {
+	bf, ok := flag.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+func normalizeBool(val string) string {
+	switch strings.ToLower(val) {
+	case "yes", "on", "enabled":
+		return "true"
+	case "no", "off", "disabled":
+		return "false"
+	default:
+		return val
+	}
+}
+
+// isMap reports whether the flag holds a map.
+func isMap(flag *flagr.Flag) bool {
+	g, ok := flag.Value.(stdflag.Getter)
+	if !ok {
+		return false
+	}
+	t := reflect.TypeOf(g.Get())
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Map
+}
+
+// jsonValues decodes s as either a JSON array or object (as "key=value" pairs)
+// and returns its elements as strings.
+func jsonValues(s string, object bool) ([]string, error) {
+	if object {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		ret := make([]string, len(keys))
+		for i, k := range keys {
+			ret[i] = k + "=" + jsonString(m[k])
+		}
+		return ret, nil
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &arr); err != nil {
+		return nil, err
+	}
+	ret := make([]string, len(arr))
+	for i, raw := range arr {
+		ret[i] = jsonString(raw)
+	}
+	return ret, nil
+}
+
+// jsonString returns the contents of raw if it is a string, or raw itself otherwise.
+func jsonString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
 func maybeParseEnvFile(path string, ignoreMissing bool) (map[string]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -3,14 +3,19 @@ package env_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/flga/flagr"
 	"github.com/flga/flagr/env"
+	"github.com/flga/flagr/file"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -158,6 +163,17 @@ func TestMatchesFlagsToEnv(t *testing.T) {
 	if diff := cmp.Diff(want, buf.String()); diff != "" {
 		t.Errorf("values mismatch (-want +got):\n%s", diff)
 	}
+
+	for name, want := range map[string]flagr.SourceInfo{
+		"a1": {Kind: "flags"},
+		"a2": {Kind: "env", Detail: "APP_A2"},
+		"a3": {Kind: "envfile", Detail: "APP_A3"},
+		"c2": {Kind: "env", Detail: "APP_C2"},
+	} {
+		if got, _ := set.SourceInfo(name); got != want {
+			t.Errorf("SourceInfo(%q) = %+v, want %+v", name, got, want)
+		}
+	}
 }
 
 func TestFailsOnInvalidVals(t *testing.T) {
@@ -229,6 +245,760 @@ func TestInvalidFile(t *testing.T) {
 	})
 }
 
+func TestObserver(t *testing.T) {
+	type observation struct {
+		FlagName, EnvName, Value string
+		Applied                  bool
+	}
+
+	var set flagr.Set
+	a := flagr.Add(&set, "a", flagr.String("a"), "")
+	b := flagr.Add(&set, "b", flagr.String("b"), "")
+	flagr.Add(&set, "c", flagr.String("c"), "")
+	d := flagr.Add(&set, "d", flagr.Bool(false), "")
+
+	var got []observation
+	if err := set.Parse(
+		[]string{"-a", "flags"},
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithTruthyBools(),
+			env.WithLookupFunc(testLookuper(
+				"APP_A", "env",
+				"APP_B", "env",
+				"APP_D", "yes",
+			)),
+			env.WithObserver(func(flagName, envName, value string, applied bool) {
+				got = append(got, observation{flagName, envName, value, applied})
+			}),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []observation{
+		{FlagName: "a", EnvName: "APP_A", Value: "env", Applied: false},
+		{FlagName: "b", EnvName: "APP_B", Value: "env", Applied: true},
+		{FlagName: "d", EnvName: "APP_D", Value: "yes", Applied: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("observations mismatch (-want +got):\n%s", diff)
+	}
+
+	if want := "flags"; *a != want {
+		t.Errorf("a = %v, want %v", *a, want)
+	}
+	if want := "env"; *b != want {
+		t.Errorf("b = %v, want %v", *b, want)
+	}
+	if !*d {
+		t.Errorf("d = %v, want true", *d)
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	parse := func(opts ...env.Option) (a, b, c string) {
+		t.Helper()
+
+		var set flagr.Set
+		pa := flagr.Add(&set, "a", flagr.String("a"), "")
+		pb := flagr.Add(&set, "b", flagr.String("b"), "")
+		pc := flagr.Add(&set, "c", flagr.String("c"), "")
+		opts = append([]env.Option{
+			env.WithPrefix("app"),
+			env.WithStaticDotEnv("testdata/empty.env", false),
+			env.WithLookupFunc(testLookuper(
+				"APP_A", "",
+				"APP_C", "",
+			)),
+		}, opts...)
+		if err := set.Parse(nil, env.Parse(opts...)); err != nil {
+			t.Fatal(err)
+		}
+		return *pa, *pb, *pc
+	}
+
+	t.Run("applies empty values by default", func(t *testing.T) {
+		a, b, c := parse()
+		if want := ""; a != want {
+			t.Errorf("a = %q, want %q", a, want)
+		}
+		if want := ""; b != want {
+			t.Errorf("b = %q, want %q", b, want)
+		}
+		if want := ""; c != want {
+			t.Errorf("c = %q, want %q", c, want)
+		}
+	})
+
+	t.Run("skips empty values", func(t *testing.T) {
+		a, b, c := parse(env.WithSkipEmpty())
+		if want := "a"; a != want {
+			t.Errorf("a = %q, want %q", a, want)
+		}
+		if want := "b"; b != want {
+			t.Errorf("b = %q, want %q", b, want)
+		}
+		if want := "file"; c != want {
+			t.Errorf("c = %q, want %q", c, want)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Setenv("FLAGR_SNAPSHOT_A", "a=b")
+	t.Setenv("FLAGR_SNAPSHOT_B", "")
+
+	lookup := env.Snapshot()
+	t.Setenv("FLAGR_SNAPSHOT_C", "c")
+
+	tests := []struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		{name: "FLAGR_SNAPSHOT_A", want: "a=b", wantOk: true},
+		{name: "FLAGR_SNAPSHOT_B", want: "", wantOk: true},
+		{name: "FLAGR_SNAPSHOT_C", want: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := lookup(tt.name)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("lookup(%q) = (%q, %v), want (%q, %v)", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	const n = 500
+	for i := 0; i < n; i++ {
+		b.Setenv(fmt.Sprintf("APP_FLAG_%d", i), strconv.Itoa(i))
+	}
+
+	bench := func(b *testing.B, lookup func() env.LookupFunc) {
+		for i := 0; i < b.N; i++ {
+			var set flagr.Set
+			for j := 0; j < n; j++ {
+				flagr.Add(&set, fmt.Sprintf("flag_%d", j), flagr.Int(0), "")
+			}
+			if err := set.Parse(nil, env.Parse(
+				env.WithPrefix("app"),
+				env.WithLookupFunc(lookup()),
+			)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("os.LookupEnv", func(b *testing.B) {
+		bench(b, func() env.LookupFunc { return os.LookupEnv })
+	})
+	b.Run("Snapshot", func(b *testing.B) {
+		bench(b, env.Snapshot)
+	})
+}
+
+func TestNameTransform(t *testing.T) {
+	var set flagr.Set
+	a := flagr.Add(&set, "a-b", flagr.String("a"), "")
+	b := flagr.Add(&set, "c", flagr.String("c"), "")
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithNameTransform(strings.ToLower),
+			env.WithLookupFunc(testLookuper(
+				"app_a_b", "env",
+				"APP_C", "env",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "env"; *a != want {
+		t.Errorf("a = %v, want %v", *a, want)
+	}
+	if want := "c"; *b != want {
+		t.Errorf("b = %v, want %v", *b, want)
+	}
+
+	var buf bytes.Buffer
+	set.FprintValues(&buf)
+	want := `Current configuration:
+  -a-b env (env: app_a_b)
+  -c c     (default)
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnprefixedFallback(t *testing.T) {
+	var set flagr.Set
+	addr := flagr.Add(&set, "http-addr", flagr.String("default"), "")
+	port := flagr.Add(&set, "port", flagr.Int(0), "")
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithUnprefixedFallback(),
+			env.WithLookupFunc(testLookuper(
+				"HTTP_ADDR", "unprefixed",
+				"PORT", "1",
+				"APP_PORT", "2",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "unprefixed"; *addr != want {
+		t.Errorf("addr = %v, want %v", *addr, want)
+	}
+	if want := 2; *port != want {
+		t.Errorf("port = %v, want %v", *port, want)
+	}
+
+	var buf bytes.Buffer
+	set.FprintValues(&buf)
+	want := `Current configuration:
+  -http-addr unprefixed (env: HTTP_ADDR)
+  -port 2               (env: APP_PORT)
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var set flagr.Set
+	ints := flagr.Add(&set, "ints", flagr.Ints(9), "")
+	strs := flagr.Add(&set, "strs", flagr.Strings(), "")
+	scalar := flagr.Add(&set, "scalar", flagr.String(""), "")
+	m := flagr.Add(&set, "map", flagr.Var(map[string]string{}, func(m *map[string]string, s string) error {
+		k, v, _ := strings.Cut(s, "=")
+		(*m)[k] = v
+		return nil
+	}), "")
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithJSON(),
+			env.WithMapper(env.DefaultMapper(",")),
+			env.WithLookupFunc(testLookuper(
+				"INTS", "[1, 2, 3]",
+				"STRS", `["a,b", "c", 4]`,
+				"SCALAR", `["x"]`,
+				"MAP", `{"b": "2", "a": 1}`,
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]int{1, 2, 3}, *ints); diff != "" {
+		t.Errorf("ints mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"a,b", "c", "4"}, *strs); diff != "" {
+		t.Errorf("strs mismatch (-want +got):\n%s", diff)
+	}
+	if want := `["x"]`; *scalar != want {
+		t.Errorf("scalar = %q, want %q", *scalar, want)
+	}
+	if diff := cmp.Diff(map[string]string{"a": "1", "b": "2"}, *m); diff != "" {
+		t.Errorf("map mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	var set flagr.Set
+	set.SetOutput(io.Discard)
+	flagr.Add(&set, "ints", flagr.Ints(), "")
+	err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithJSON(),
+			env.WithLookupFunc(testLookuper("INTS", "1,2,3")),
+		),
+	)
+	if err == nil || !strings.HasPrefix(err.Error(), "env: invalid json value for INTS: ") {
+		t.Errorf("err = %v, want invalid json error", err)
+	}
+}
+
+func TestTruthyBools(t *testing.T) {
+	tests := map[string]bool{
+		"yes":      true,
+		"On":       true,
+		"ENABLED":  true,
+		"no":       false,
+		"off":      false,
+		"Disabled": false,
+		"1":        true,
+		"false":    false,
+	}
+	for val, want := range tests {
+		t.Run(val, func(t *testing.T) {
+			var set flagr.Set
+			debug := flagr.Add(&set, "debug", flagr.Bool(!want), "")
+			mode := flagr.Add(&set, "mode", flagr.String(""), "")
+			if err := set.Parse(
+				nil,
+				env.Parse(
+					env.WithPrefix("app"),
+					env.WithTruthyBools(),
+					env.WithLookupFunc(testLookuper(
+						"APP_DEBUG", val,
+						"APP_MODE", val,
+					)),
+				),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if *debug != want {
+				t.Errorf("debug = %v, want %v", *debug, want)
+			}
+			if *mode != val {
+				t.Errorf("mode = %q, want %q", *mode, val)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		flagr.Add(&set, "debug", flagr.Bool(false), "")
+		err := set.Parse(nil, env.Parse(env.WithLookupFunc(testLookuper("DEBUG", "yes"))))
+		if err == nil {
+			t.Errorf("err = nil, want error")
+		}
+	})
+}
+
+func TestProvider(t *testing.T) {
+	params := map[string]string{
+		"a":    "provided",
+		"list": "1,2",
+	}
+	provider := func(flagName string) (string, bool, error) {
+		if flagName == "broken" {
+			return "", false, errors.New("access denied")
+		}
+		v, ok := params[flagName]
+		return v, ok, nil
+	}
+
+	var set flagr.Set
+	a := flagr.Add(&set, "a", flagr.String("default"), "")
+	b := flagr.Add(&set, "b", flagr.String("default"), "")
+	list := flagr.Add(&set, "list", flagr.Ints(), "")
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithProvider(provider),
+			env.WithMapper(env.DefaultMapper(",")),
+			env.WithLookupFunc(testLookuper("B", "env")),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "provided"; *a != want {
+		t.Errorf("a = %q, want %q", *a, want)
+	}
+	if want := "default"; *b != want {
+		t.Errorf("b = %q, want %q", *b, want)
+	}
+	if diff := cmp.Diff([]int{1, 2}, *list); diff != "" {
+		t.Errorf("list mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := set.Provenance()["a"].Source, flagr.Source("provider: a"); got != want {
+		t.Errorf("source = %q, want %q", got, want)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		flagr.Add(&set, "a", flagr.String("default"), "")
+		flagr.Add(&set, "broken", flagr.String("default"), "")
+		err := set.Parse(nil, env.Parse(env.WithProvider(provider)))
+		if want := "env: provider failed for broken: access denied"; err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	})
+}
+
+func TestJSONBlob(t *testing.T) {
+	var set flagr.Set
+	addr := flagr.Add(&set, "http-addr", flagr.String(""), "")
+	port := flagr.Add(&set, "http-port", flagr.Int(0), "")
+	hosts := flagr.Add(&set, "hosts", flagr.Strings(), "")
+	debug := flagr.Add(&set, "debug", flagr.Bool(false), "")
+	missing := flagr.Add(&set, "missing", flagr.String("default"), "")
+
+	mapper := file.TableMapper(map[string]file.KeyPath{
+		"http-addr": "http.addr",
+		"http-port": "http.port",
+	})
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithJSONBlob("APP_CONFIG", mapper),
+			env.WithLookupFunc(testLookuper(
+				"APP_CONFIG", `{"http": {"addr": "blob", "port": 8080}, "hosts": ["a", "b"], "debug": true}`,
+				"APP_HTTP_ADDR", "env",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "env"; *addr != want {
+		t.Errorf("addr = %q, want %q", *addr, want)
+	}
+	if want := 8080; *port != want {
+		t.Errorf("port = %d, want %d", *port, want)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, *hosts); diff != "" {
+		t.Errorf("hosts mismatch (-want +got):\n%s", diff)
+	}
+	if !*debug {
+		t.Errorf("debug = false, want true")
+	}
+	if want := "default"; *missing != want {
+		t.Errorf("missing = %q, want %q", *missing, want)
+	}
+
+	var buf bytes.Buffer
+	set.FprintValues(&buf)
+	want := `Current configuration:
+  -debug true      (env-json[APP_CONFIG]: debug)
+  -hosts [a, b]    (env-json[APP_CONFIG]: hosts)
+  -http-addr env   (env: APP_HTTP_ADDR)
+  -http-port 8080  (env-json[APP_CONFIG]: http.port)
+  -missing default (default)
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		flagr.Add(&set, "a", flagr.String(""), "")
+		err := set.Parse(nil, env.Parse(
+			env.WithJSONBlob("CONFIG", nil),
+			env.WithLookupFunc(testLookuper("CONFIG", "[1]")),
+		))
+		if err == nil || !strings.HasPrefix(err.Error(), "env: invalid json in CONFIG: ") {
+			t.Errorf("err = %v, want invalid json error", err)
+		}
+	})
+}
+
+func TestUnquote(t *testing.T) {
+	tests := map[string]string{
+		`"svc"`:   "svc",
+		`'svc'`:   "svc",
+		`""svc""`: `"svc"`,
+		`"svc'`:   `"svc'`,
+		`"`:       `"`,
+		`""`:      "",
+		`svc`:     "svc",
+		`"a" "b"`: `a" "b`,
+		`x"svc"`:  `x"svc"`,
+	}
+	for val, want := range tests {
+		t.Run(val, func(t *testing.T) {
+			var set flagr.Set
+			name := flagr.Add(&set, "name", flagr.String(""), "")
+			if err := set.Parse(
+				nil,
+				env.Parse(
+					env.WithUnquote(),
+					env.WithLookupFunc(testLookuper("NAME", val)),
+				),
+			); err != nil {
+				t.Fatal(err)
+			}
+			if *name != want {
+				t.Errorf("name = %q, want %q", *name, want)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	t.Setenv("APP_FOO", "env")
+	t.Setenv("APP_HTTP_ADDR", "env")
+	t.Setenv("BAR", "env")
+
+	var set flagr.Set
+	foo := flagr.Add(&set, "foo", flagr.String(""), "")
+	addr := flagr.Add(&set, "http-addr", flagr.String(""), "")
+	bar := flagr.Add(&set, "bar", flagr.String("default"), "")
+	if err := env.Bind(&set, []string{"-http-addr", "flag"}, "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "env"; *foo != want {
+		t.Errorf("foo = %q, want %q", *foo, want)
+	}
+	if want := "flag"; *addr != want {
+		t.Errorf("http-addr = %q, want %q", *addr, want)
+	}
+	if want := "default"; *bar != want {
+		t.Errorf("bar = %q, want %q", *bar, want)
+	}
+}
+
+func TestIndexedLists(t *testing.T) {
+	var set flagr.Set
+	hosts := flagr.Add(&set, "host", flagr.Strings(), "")
+	ports := flagr.Add(&set, "port", flagr.Ints(), "")
+	name := flagr.Add(&set, "name", flagr.String(""), "")
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithMapper(env.DefaultMapper(",")),
+			env.WithIndexedLists(),
+			env.WithLookupFunc(testLookuper(
+				"APP_HOST", "a,b",
+				"APP_PORT_0", "80",
+				"APP_PORT_1", "443",
+				"APP_PORT_3", "8080",
+				"APP_NAME_0", "ignored",
+				"APP_NAME", "name",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"a", "b"}, *hosts); diff != "" {
+		t.Errorf("host mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{80, 443}, *ports); diff != "" {
+		t.Errorf("port mismatch (-want +got):\n%s", diff)
+	}
+	if want := "name"; *name != want {
+		t.Errorf("name = %q, want %q", *name, want)
+	}
+	if got, want := set.Provenance()["port"].Source, flagr.Source("env: APP_PORT_1"); got != want {
+		t.Errorf("port source = %q, want %q", got, want)
+	}
+}
+
+func TestIndexedListsOrder(t *testing.T) {
+	var kv []string
+	var want []int
+	for i := 0; i < 12; i++ {
+		kv = append(kv, fmt.Sprintf("PORT_%d", i), strconv.Itoa(100-i))
+		want = append(want, 100-i)
+	}
+
+	for i := 0; i < 3; i++ {
+		var set flagr.Set
+		ports := flagr.Add(&set, "port", flagr.Ints(), "")
+		if err := set.Parse(nil, env.Parse(env.WithIndexedLists(), env.WithLookupFunc(testLookuper(kv...)))); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, *ports); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestIndexedListsMixed(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "host", flagr.Strings(), "")
+	err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithIndexedLists(),
+			env.WithLookupFunc(testLookuper(
+				"HOST", "a,b",
+				"HOST_0", "c",
+			)),
+		),
+	)
+	if want := "env: both HOST and HOST_0 are set, use only one form"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestNameCollector(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "http-addr", flagr.String(""), "")
+	flagr.Add(&set, "db.url", flagr.String(""), "")
+	flagr.Add(&set, "workers", flagr.Int(1), "")
+
+	var names []string
+	if err := set.Parse(
+		[]string{"-workers", "2"},
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithNameCollector(&names),
+			env.WithLookupFunc(testLookuper("APP_DB_URL", "x")),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"APP_DB_URL", "APP_HTTP_ADDR", "APP_WORKERS"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReference(t *testing.T) {
+	var set flagr.Set
+	addr := flagr.Add(&set, "addr", flagr.String(""), "")
+	metricsAddr := flagr.Add(&set, "metrics-addr", flagr.String(""), "")
+	debugAddr := flagr.Add(&set, "debug-addr", flagr.String(""), "")
+	if err := set.Parse(
+		[]string{"-addr", ":8080"},
+		env.Parse(
+			env.WithReference("@flag:"),
+			env.WithLookupFunc(testLookuper(
+				"METRICS_ADDR", "@flag:addr",
+				"DEBUG_ADDR", ":6060",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if want := ":8080"; *addr != want {
+		t.Errorf("addr = %q, want %q", *addr, want)
+	}
+	if want := ":8080"; *metricsAddr != want {
+		t.Errorf("metrics-addr = %q, want %q", *metricsAddr, want)
+	}
+	if want := ":6060"; *debugAddr != want {
+		t.Errorf("debug-addr = %q, want %q", *debugAddr, want)
+	}
+
+	var other flagr.Set
+	flagr.Add(&other, "addr", flagr.String(""), "")
+	err := other.Parse(nil, env.Parse(
+		env.WithReference("@flag:"),
+		env.WithLookupFunc(testLookuper("ADDR", "@flag:nope")),
+	))
+	if want := `env: ADDR references unknown flag "nope"`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	type dbConfig struct {
+		URL     string `env:"DATABASE_URL"`
+		Pool    int
+		Timeout time.Duration `env:"DB_TIMEOUT" flag:"timeout"`
+	}
+
+	var set flagr.Set
+	db := flagr.StructFlag(&set, "db", dbConfig{Pool: 1})
+	other := flagr.Add(&set, "other", flagr.String(""), "")
+
+	var names []string
+	if err := set.Parse(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithStructTags(db, "env"),
+			env.WithNameCollector(&names),
+			env.WithLookupFunc(testLookuper(
+				"DATABASE_URL", "postgres://db",
+				"APP_DB_URL", "ignored",
+				"APP_DB_POOL", "4",
+				"DB_TIMEOUT", "3s",
+				"APP_OTHER", "other",
+			)),
+		),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	want := dbConfig{URL: "postgres://db", Pool: 4, Timeout: 3 * time.Second}
+	if diff := cmp.Diff(want, *db); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want := "other"; *other != want {
+		t.Errorf("other = %q, want %q", *other, want)
+	}
+	if diff := cmp.Diff([]string{"APP_DB_POOL", "DB_TIMEOUT", "DATABASE_URL", "APP_OTHER"}, names); diff != "" {
+		t.Errorf("names mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildReverse(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "http-addr", flagr.String(""), "")
+	flagr.Add(&set, "db.url", flagr.String(""), "")
+
+	got, err := env.BuildReverse(&set, env.DefaultMapper(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"HTTP_ADDR": "http-addr",
+		"DB_URL":    "db.url",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	flagr.Add(&set, "http.addr", flagr.String(""), "")
+	flagr.Add(&set, "http_addr", flagr.String(""), "")
+	got, err = env.BuildReverse(&set, env.DefaultMapper(""))
+	if want := "env: name collision: HTTP_ADDR is mapped from http-addr, http.addr, http_addr"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDotEnvMapper(t *testing.T) {
+	// not visible through the lookup func, so it must not be reported
+	t.Setenv("APP_STRAY", "x")
+
+	var set flagr.Set
+	port := flagr.Add(&set, "port", flagr.Int(0), "")
+	host := flagr.Add(&set, "host", flagr.String(""), "")
+	err := set.ParseStrict(
+		nil,
+		env.Parse(
+			env.WithPrefix("app"),
+			env.WithStaticDotEnv("testdata/unprefixed.env", false),
+			env.WithDotEnvMapper(env.DefaultMapper("")),
+			env.WithReportUnused(),
+			env.WithLookupFunc(testLookuper(
+				"APP_PORT", "9090",
+				"HOST", "process-host",
+			)),
+		),
+	)
+	if want := "unused values: envfile[testdata/unprefixed.env]: STALE"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+
+	if *port != 9090 {
+		t.Errorf("port = %d, want 9090", *port)
+	}
+	if want := "file-host"; *host != want {
+		t.Errorf("host = %q, want %q", *host, want)
+	}
+	info, _ := set.SourceInfo("host")
+	if diff := cmp.Diff(flagr.SourceInfo{Kind: "envfile", Detail: "HOST"}, info); diff != "" {
+		t.Errorf("host info mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func testLookuper(kv ...string) env.LookupFunc {
 	env := make(map[string]string)
 	for i, kOrV := range kv {
@@ -0,0 +1,32 @@
+package flagr_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/flga/flagr"
+)
+
+func ExampleIOArg() {
+	var set flagr.Set
+
+	in := flagr.Add(&set, "in", flagr.IOArg("-"), "input file, - for stdin")
+	out := flagr.Add(&set, "out", flagr.IOArg("-"), "output file, - for stdout")
+
+	args := []string{
+		"-in", "data.csv",
+	}
+	if err := set.Parse(args); err != nil {
+		if errors.Is(err, flagr.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	fmt.Printf("in = %s, std = %v\n", in.Path, in.IsStd)
+	fmt.Printf("out = %s, std = %v\n", out.Path, out.IsStd)
+	// Output:
+	// in = data.csv, std = false
+	// out = -, std = true
+}
@@ -0,0 +1,36 @@
+package flagr_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flga/flagr"
+)
+
+func ExampleMapValue() {
+	var set flagr.Set
+
+	// parse the flag as a regular string and make it absolute, relative to /srv
+	dir := flagr.Add(&set, "dir", flagr.MapValue(flagr.String("."), func(path string) (string, error) {
+		if filepath.IsAbs(path) {
+			return filepath.Clean(path), nil
+		}
+		return filepath.Join("/srv", path), nil
+	}), "data directory")
+
+	args := []string{
+		"-dir", "data/../www",
+	}
+	if err := set.Parse(args); err != nil {
+		if errors.Is(err, flagr.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	fmt.Println(*dir)
+	// Output:
+	// /srv/www
+}
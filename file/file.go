@@ -2,6 +2,7 @@
 package file
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,10 +10,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"strconv"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/flga/flagr"
+	"github.com/flga/flagr/internal/tree"
 )
 
 // KeyPathSeparator is the value used to separate sub paths in path expressions.
@@ -59,6 +62,17 @@ type Mapper func(flagName string) KeyPath
 // NoopMapper returns the given flag name as is.
 func NoopMapper(flagName string) KeyPath { return KeyPath(flagName) }
 
+// TableMapper returns a [Mapper] that maps flag names using the given table.
+// Flags that are not present in the table are mapped with [NoopMapper].
+func TableMapper(table map[string]KeyPath) Mapper {
+	return func(flagName string) KeyPath {
+		if key, ok := table[flagName]; ok {
+			return key
+		}
+		return NoopMapper(flagName)
+	}
+}
+
 // DecoderFunc is a function that deserializes data into v. Functions like
 // json.Unmarshal conform to this type.
 type DecoderFunc func(data []byte, v interface{}) error
@@ -71,21 +85,36 @@ type Extension string
 // encouraged to map both ".yaml" and ".yml" to yaml.Unmarshal.
 type Mux map[Extension]DecoderFunc
 
-func (m Mux) supportedExts() []Extension {
-	ret := make([]Extension, len(m))
-	i := 0
-	for k := range m {
-		ret[i] = k
-		i++
+// SupportedExtensions returns the extensions handled by mux, sorted. It is
+// useful to tell users which config file formats are accepted, such as in the
+// usage message.
+func SupportedExtensions(mux Mux) []Extension {
+	ret := make([]Extension, 0, len(mux))
+	for k := range mux {
+		ret = append(ret, k)
 	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
 	return ret
 }
 
 // Options contains all the options used to parse a config file.
 type Options struct {
-	Mapper            Mapper // Maps flag names to property paths
-	IgnoreMissingFile bool   // If true, we don't treat [fs.ErrNotExist] as an error.
-	FS                fs.FS  // If provided, this will be used instead of the primary filesystem.
+	Mapper             Mapper   // Maps flag names to property paths
+	IgnoreMissingFile  bool     // If true, we don't treat [fs.ErrNotExist] as an error.
+	FS                 fs.FS    // If provided, this will be used instead of the primary filesystem.
+	ArrayToScalarError bool     // If true, assigning an array to a non repeatable flag is an error.
+	Mappers            []Mapper // If provided, these are tried in order, instead of Mapper, until one finds a value.
+	ReportUnused       bool     // If true, keys that don't map to any flag are reported to the [flagr.Set].
+	RequireFile        bool     // If true, [fs.ErrNotExist] is always an error, even if IgnoreMissingFile is set.
+	TrimLeadingSpace   bool     // If true, leading whitespace is removed before decoding.
+
+	VersionKey        KeyPath          // If provided, the file must have a version at this path.
+	SupportedVersions []string         // The versions accepted in VersionKey.
+	VersionExtract    func(any) string // Converts the decoded version to a string, defaults to [fmt.Sprint].
+
+	ValueTransform func(key KeyPath, raw string) (string, error) // If provided, applied to every value before it is set.
+
+	MultiDoc func([]map[string]any) (map[string]any, error) // If provided, the decoder produces a list of documents and this picks the one to use.
 }
 
 // Option is a function that mutates Options.
@@ -99,6 +128,15 @@ func WithMapper(n Mapper) Option {
 	}
 }
 
+// WithMappers configures Parser to try each of the given [Mapper] in order,
+// using the first [KeyPath] that resolves to a value. If provided, the [Mapper]
+// given to [WithMapper] is not used.
+func WithMappers(mappers ...Mapper) Option {
+	return func(o *Options) {
+		o.Mappers = mappers
+	}
+}
+
 // IgnoreMissingFile makes it so that if the provided file doesn't exist, it is
 // not considered an error.
 func IgnoreMissingFile() Option {
@@ -107,6 +145,26 @@ func IgnoreMissingFile() Option {
 	}
 }
 
+// RequireFile makes it so that if the provided file doesn't exist, it is always
+// considered an error. It takes precedence over [IgnoreMissingFile].
+//
+// This is useful when the path is given by the user, such as with a "-config"
+// flag, where a missing file is most likely a mistake.
+func RequireFile() Option {
+	return func(o *Options) {
+		o.RequireFile = true
+	}
+}
+
+// TrimLeadingSpace makes it so that leading whitespace is removed from the file
+// contents before decoding. Do not use it with formats where leading whitespace
+// is significant, such as yaml.
+func TrimLeadingSpace() Option {
+	return func(o *Options) {
+		o.TrimLeadingSpace = true
+	}
+}
+
 // With FS configures the Parser such that the file is retrieved from the given
 // fs instead of the primary filesystem.
 func WithFS(fs fs.FS) Option {
@@ -115,6 +173,105 @@ func WithFS(fs fs.FS) Option {
 	}
 }
 
+// WithArrayToScalarError makes it so that assigning an array to a flag that is
+// not repeatable is considered an error, instead of silently using the last value
+// of the array.
+//
+// Repeatability is determined by [flagr.IsRepeatable].
+func WithArrayToScalarError() Option {
+	return func(o *Options) {
+		o.ArrayToScalarError = true
+	}
+}
+
+// WithVersionKey makes the parser read the version of the file from key and fail
+// with [ErrVersion] if it is missing or not one of supported. This guards against
+// loading config files in a format the program does not understand.
+//
+// The decoded value is converted to a string with extract, or [fmt.Sprint] if it
+// is nil, so that a json or yaml "version: 2" matches "2".
+//
+// The key is not treated as a flag, it is never assigned nor reported as unused.
+func WithVersionKey(key KeyPath, supported []string, extract func(any) string) Option {
+	return func(o *Options) {
+		o.VersionKey = key
+		o.SupportedVersions = supported
+		o.VersionExtract = extract
+	}
+}
+
+// WithValueTransform makes the parser call fn with every value, after converting
+// it to a string and before setting the flag, and use the result instead. Slices
+// call fn once per element.
+//
+// It is an escape hatch to adjust values stored in a form the flag does not
+// accept, such as uppercase enums. Errors are returned as [ErrVal].
+func WithValueTransform(fn func(key KeyPath, raw string) (string, error)) Option {
+	return func(o *Options) {
+		o.ValueTransform = fn
+	}
+}
+
+// WithMultiDoc makes the parser decode files into a []map[string]any, one map per
+// document, as in yaml streams separated by "---", and use selector to pick or
+// build the document that flags are assigned from. The decoders in the [Mux] must
+// support decoding into a *[]map[string]any.
+//
+// [SelectDocument] and [MergeDocuments] cover the common cases. Errors returned
+// by selector are returned as [ErrDecode].
+func WithMultiDoc(selector func([]map[string]any) (map[string]any, error)) Option {
+	return func(o *Options) {
+		o.MultiDoc = selector
+	}
+}
+
+// SelectDocument returns a selector for [WithMultiDoc] that picks the document at
+// index i, failing if there are not enough documents.
+func SelectDocument(i int) func([]map[string]any) (map[string]any, error) {
+	return func(docs []map[string]any) (map[string]any, error) {
+		if i < 0 || i >= len(docs) {
+			return nil, fmt.Errorf("document %d not found, got %d documents", i, len(docs))
+		}
+		return docs[i], nil
+	}
+}
+
+// MergeDocuments is a selector for [WithMultiDoc] that merges all documents into
+// one, in order. Nested objects are merged recursively, any other value in a
+// later document replaces the one in an earlier document.
+func MergeDocuments(docs []map[string]any) (map[string]any, error) {
+	ret := make(map[string]any)
+	for _, doc := range docs {
+		mergeInto(ret, doc)
+	}
+	return ret, nil
+}
+
+func mergeInto(dst, src map[string]any) {
+	for k, v := range src {
+		srcChild, ok := v.(map[string]any)
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dstChild, ok := dst[k].(map[string]any)
+		if !ok {
+			dstChild = make(map[string]any)
+			dst[k] = dstChild
+		}
+		mergeInto(dstChild, srcChild)
+	}
+}
+
+// WithReportUnused makes the parser report, trough [flagr.Set.ReportUnused], any
+// key in the file that does not correspond to a flag, so that [flagr.Set.ParseStrict]
+// can fail on them.
+func WithReportUnused() Option {
+	return func(o *Options) {
+		o.ReportUnused = true
+	}
+}
+
 // Parse returns a [flagr.FlagParser] that parses the file stored in path and
 // assigns the results to any flags that have not yet been set.
 //
@@ -127,7 +284,8 @@ func WithFS(fs fs.FS) Option {
 //		"foo": 42
 //	}
 //
-// So is this (assuming that foo is repeatable, otherwise it will be set to the last value of the array):
+// So is this (assuming that foo is repeatable, otherwise it will be set to the last value of the array,
+// or fail if [WithArrayToScalarError] is provided):
 //
 //	{
 //		"foo": [1, 2, 3]
@@ -141,7 +299,7 @@ func WithFS(fs fs.FS) Option {
 //
 // The file contents are read and decoded using the decoder mapped to the file's
 // extension. If decoding fails it returns [ErrDecode], if no suitable decoder is found
-// it returns [ErrUnsupported].
+// it returns [ErrUnsupported]. A leading UTF-8 BOM is removed before decoding.
 //
 // File decoding is controlled by [Mux]. At least one mapping must be provided.
 //
@@ -158,6 +316,9 @@ func WithFS(fs fs.FS) Option {
 // if this fails we will return the error.
 // If we are unable to convert the value to a string (for example, if it's an object)
 // [ErrVal] will be returned containing the key that failed and the error.
+//
+// Values are recorded with the source "file[<path>]: <key>", and with a
+// [flagr.SourceInfo] of kind "file" whose detail is the key.
 func Parse(path *string, mux Mux, options ...Option) flagr.Parser {
 	if path == nil {
 		panic("file: path cannot be nil")
@@ -182,7 +343,7 @@ func Parse(path *string, mux Mux, options ...Option) flagr.Parser {
 	return func(set *flagr.Set) error {
 		f, err := opts.FS.Open(*path)
 		if err != nil {
-			if errors.Is(err, fs.ErrNotExist) && opts.IgnoreMissingFile {
+			if errors.Is(err, fs.ErrNotExist) && opts.IgnoreMissingFile && !opts.RequireFile {
 				return nil
 			}
 			return fmt.Errorf("file: %w", err)
@@ -194,36 +355,109 @@ func Parse(path *string, mux Mux, options ...Option) flagr.Parser {
 			return fmt.Errorf("file: %w", err)
 		}
 
+		// files edited on windows may start with a BOM, which most decoders reject
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		if opts.TrimLeadingSpace {
+			data = bytes.TrimLeftFunc(data, unicode.IsSpace)
+		}
+
 		ext := Extension(filepath.Ext(*path))
 		decoder, found := mux[ext]
 		if !found {
 			return ErrUnsupported{
 				Ext:       ext,
-				Available: mux.supportedExts(),
+				Available: SupportedExtensions(mux),
 			}
 		}
 
 		var values map[string]any
-		if err := decoder(data, &values); err != nil {
+		if opts.MultiDoc != nil {
+			var docs []map[string]any
+			if err := decoder(data, &docs); err != nil {
+				return ErrDecode{err}
+			}
+			if values, err = opts.MultiDoc(docs); err != nil {
+				return ErrDecode{err}
+			}
+		} else if err := decoder(data, &values); err != nil {
 			return ErrDecode{err}
 		}
 
+		if opts.VersionKey != "" {
+			if err := checkVersion(values, opts); err != nil {
+				return err
+			}
+		}
+
+		mappers := opts.Mappers
+		if len(mappers) == 0 {
+			mappers = []Mapper{opts.Mapper}
+		}
+
+		if opts.ReportUnused {
+			known := make(map[KeyPath]bool)
+			if opts.VersionKey != "" {
+				known[opts.VersionKey] = true
+			}
+			set.VisitAll(func(f *flagr.Flag) error {
+				for _, mapper := range mappers {
+					known[mapper(f.Name)] = true
+				}
+				return nil
+			})
+
+			var unused []string
+			for _, key := range unusedKeys(values, "", known) {
+				unused = append(unused, fmt.Sprintf("file[%s]: %s", *path, key))
+			}
+			set.ReportUnused(unused)
+		}
+
 		return set.VisitRemaining(func(f *flagr.Flag) error {
-			key := opts.Mapper(f.Name)
-			wrapper, ok := find(values, key)
-			if !ok {
+			var key KeyPath
+			var wrapper reflect.Value
+			var ok bool
+			for _, mapper := range mappers {
+				key = mapper(f.Name)
+				if wrapper, ok = tree.Find(values, key.Split()); ok {
+					break
+				}
+			}
+			if !ok || opts.VersionKey != "" && key == opts.VersionKey {
 				return nil
 			}
 
+			if opts.ArrayToScalarError && wrapper.Kind() == reflect.Slice && !flagr.IsRepeatable(f) {
+				return ErrVal{
+					Key:  key,
+					Type: wrapper.Type().String(),
+					Err:  fmt.Errorf("cannot assign an array to non repeatable flag %q", f.Name),
+				}
+			}
+
 			var vals []string
-			if err := stringify(wrapper, &vals); err != nil {
+			if err := tree.Stringify(wrapper, &vals); err != nil {
 				return ErrVal{
-					Key: key,
-					Err: err,
+					Key:  key,
+					Type: wrapper.Type().String(),
+					Err:  err,
 				}
 			}
+			src := flagr.Source(fmt.Sprintf("file[%s]: %s", *path, key))
+			info := flagr.SourceInfo{Kind: "file", Detail: string(key)}
 			for _, val := range vals {
-				if err := set.Set("file TODO", f.Name, val); err != nil {
+				if opts.ValueTransform != nil {
+					transformed, err := opts.ValueTransform(key, val)
+					if err != nil {
+						return ErrVal{
+							Key:  key,
+							Type: wrapper.Type().String(),
+							Err:  err,
+						}
+					}
+					val = transformed
+				}
+				if err := set.SetWithInfo(src, info, f.Name, val); err != nil {
 					return err
 				}
 			}
@@ -233,6 +467,26 @@ func Parse(path *string, mux Mux, options ...Option) flagr.Parser {
 	}
 }
 
+func checkVersion(values map[string]any, opts Options) error {
+	err := ErrVersion{Key: opts.VersionKey, Supported: opts.SupportedVersions}
+	v, ok := tree.Find(values, opts.VersionKey.Split())
+	if !ok {
+		return err
+	}
+
+	extract := opts.VersionExtract
+	if extract == nil {
+		extract = func(v any) string { return fmt.Sprint(v) }
+	}
+	err.Version = extract(v.Interface())
+	for _, supported := range opts.SupportedVersions {
+		if err.Version == supported {
+			return nil
+		}
+	}
+	return err
+}
+
 // Static is a helper for calling [Parse] with a static path.
 func Static(path string) *string { return &path }
 
@@ -244,79 +498,66 @@ func (osFS) Open(name string) (fs.File, error) {
 	return os.Open(name)
 }
 
-func find(root map[string]any, key KeyPath) (reflect.Value, bool) {
-	rv := unwrap(reflect.ValueOf(root))
-	for _, segment := range key.Split() {
-		rv = unwrap(rv.MapIndex(reflect.ValueOf(segment)))
-		if !rv.IsValid() {
-			return reflect.Value{}, false
-		}
-	}
-	return rv, true
-}
-
-func unwrap(rv reflect.Value) reflect.Value {
-	switch rv.Kind() {
-	case reflect.Interface, reflect.Pointer:
-		return rv.Elem()
-	default:
-		return rv
+// unusedKeys returns, in lexical order, the paths of all the leaves in root that
+// are not in known. Objects whose path is known are not descended into.
+func unusedKeys(root map[string]any, prefix KeyPath, known map[KeyPath]bool) []KeyPath {
+	keys := make([]string, 0, len(root))
+	for k := range root {
+		keys = append(keys, k)
 	}
-}
-
-func stringify(v reflect.Value, values *[]string) error {
-	switch v.Kind() {
-	case reflect.Bool:
-		*values = append(*values, strconv.FormatBool(v.Bool()))
-		return nil
-
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		*values = append(*values, strconv.FormatInt(v.Int(), 10))
-		return nil
-
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		*values = append(*values, strconv.FormatUint(v.Uint(), 10))
-		return nil
+	sort.Strings(keys)
 
-	case reflect.Float32, reflect.Float64:
-		*values = append(*values, strconv.FormatFloat(v.Float(), 'f', -1, 64))
-		return nil
-
-	case reflect.Interface, reflect.Pointer:
-		return stringify(v.Elem(), values)
-
-	case reflect.Slice:
-		len := v.Len()
-		for i := 0; i < len; i++ {
-			if err := stringify(v.Index(i), values); err != nil {
-				return err
-			}
+	var ret []KeyPath
+	for _, k := range keys {
+		key := KeyPath(k)
+		if prefix != "" {
+			key = prefix + KeyPathSeparator + key
 		}
-		return nil
-
-	case reflect.String:
-		*values = append(*values, v.String())
-		return nil
-
-	default:
-		return fmt.Errorf("unsupported type %q", v.Type().String())
+		if known[key] {
+			continue
+		}
+		if child, ok := root[k].(map[string]any); ok {
+			ret = append(ret, unusedKeys(child, key, known)...)
+			continue
+		}
+		ret = append(ret, key)
 	}
+	return ret
 }
 
 // ErrVal is returned when we're unable to convert a value to a string.
 type ErrVal struct {
-	Key KeyPath
-	Err error
+	Key  KeyPath // The path of the value.
+	Type string  // The Go type of the value, as produced by the decoder.
+	Err  error
 }
 
 func (e ErrVal) Error() string {
-	return fmt.Sprintf("file: invalid value for path %q: %s", e.Key, e.Err)
+	if e.Type == "" {
+		return fmt.Sprintf("file: invalid value for path %q: %s", e.Key, e.Err)
+	}
+	return fmt.Sprintf("file: invalid value of type %s for path %q: %s", e.Type, e.Key, e.Err)
 }
 
 func (e ErrVal) Unwrap() error {
 	return e.Err
 }
 
+// ErrVersion is returned when the version of the file, as configured by
+// [WithVersionKey], is missing or not supported.
+type ErrVersion struct {
+	Key       KeyPath  // The path of the version.
+	Version   string   // The version found in the file, empty if missing.
+	Supported []string // The supported versions.
+}
+
+func (e ErrVersion) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("file: missing version at path %q", e.Key)
+	}
+	return fmt.Sprintf("file: unsupported version %q at path %q, must be one of: %s", e.Version, e.Key, strings.Join(e.Supported, ", "))
+}
+
 // ErrUnsupported is returned when we could not find a [DecoderFunc] in the given
 // [Mux] with the provided file's extension.
 type ErrUnsupported struct {
@@ -3,15 +3,19 @@ package file_test
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"io/fs"
+	"net"
 	"net/netip"
 	"net/url"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/flga/flagr"
+	"github.com/flga/flagr/env"
 	"github.com/flga/flagr/file"
 	"github.com/flga/flagr/internal/testflags"
 	"github.com/google/go-cmp/cmp"
@@ -128,6 +132,64 @@ func TestParserInvariants(t *testing.T) {
 		}
 	})
 
+	t.Run("fails if file doesn't exist and require file is set, even if ignore missing is true", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		path := flagr.Add(&set, "config", flagr.String(""), "")
+		err := set.Parse(
+			[]string{"-config", "testdata/______not a file.json"},
+			file.Parse(
+				path,
+				file.Mux{".json": json.Unmarshal},
+				file.IgnoreMissingFile(),
+				file.RequireFile(),
+			),
+		)
+		if want := fs.ErrNotExist; !errors.Is(err, want) {
+			t.Fatalf("err = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("strips a leading BOM", func(t *testing.T) {
+		var set flagr.Set
+		val := flagr.Add(&set, "my-flag-name", flagr.String(""), "")
+		err := set.Parse(
+			nil,
+			file.Parse(
+				file.Static("testdata/bom.json"),
+				file.Mux{".json": json.Unmarshal},
+			),
+		)
+		if err != nil {
+			t.Fatalf("err = %v", err)
+		}
+		if want := "asd"; *val != want {
+			t.Errorf("val = %q, want %q", *val, want)
+		}
+	})
+
+	t.Run("trims leading space", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"cfg.test": &fstest.MapFile{Data: []byte("\xef\xbb\xbf \n\t{}")},
+		}
+		var got []byte
+		decoder := func(data []byte, v interface{}) error {
+			got = data
+			return nil
+		}
+		var set flagr.Set
+		err := set.Parse(
+			nil,
+			file.Parse(file.Static("cfg.test"), file.Mux{".test": decoder}, file.WithFS(fsys), file.TrimLeadingSpace()),
+		)
+		if err != nil {
+			t.Fatalf("err = %v", err)
+		}
+		if want := "{}"; string(got) != want {
+			t.Errorf("data = %q, want %q", got, want)
+		}
+	})
+
 	t.Run("fails if no decoder found", func(t *testing.T) {
 		var set flagr.Set
 		err := set.Parse(
@@ -166,8 +228,16 @@ func TestParserInvariants(t *testing.T) {
 				file.Mux{".json": json.Unmarshal},
 			),
 		)
-		if want := (file.ErrVal{}); !errors.As(err, &want) {
-			t.Fatalf("err = %v, want %v", err, want)
+		var errVal file.ErrVal
+		if !errors.As(err, &errVal) {
+			t.Fatalf("err = %v, want %v", err, file.ErrVal{})
+		}
+		if errVal.Key != "my-flag-name" || errVal.Type != "map[string]interface {}" {
+			t.Errorf("Key, Type = %q, %q, want %q, %q", errVal.Key, errVal.Type, "my-flag-name", "map[string]interface {}")
+		}
+		want := `file: invalid value of type map[string]interface {} for path "my-flag-name": cannot use an object as a flag value, expected a scalar or an array of scalars`
+		if err.Error() != want {
+			t.Errorf("err = %q, want %q", err, want)
 		}
 	})
 
@@ -258,6 +328,391 @@ func TestParserInvariants(t *testing.T) {
 	})
 }
 
+func TestArrayToScalarError(t *testing.T) {
+	t.Run("uses the last value by default", func(t *testing.T) {
+		var set flagr.Set
+		val := flagr.Add(&set, "my-flag-name", flagr.Int(0), "")
+		err := set.Parse(
+			nil,
+			file.Parse(
+				file.Static("testdata/array.json"),
+				file.Mux{".json": json.Unmarshal},
+			),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := 2; *val != want {
+			t.Errorf("val = %v, want %v", *val, want)
+		}
+	})
+
+	t.Run("fails if the flag is not repeatable", func(t *testing.T) {
+		var set flagr.Set
+		flagr.Add(&set, "my-flag-name", flagr.Int(0), "")
+		err := set.Parse(
+			nil,
+			file.Parse(
+				file.Static("testdata/array.json"),
+				file.Mux{".json": json.Unmarshal},
+				file.WithArrayToScalarError(),
+			),
+		)
+		if want := (file.ErrVal{}); !errors.As(err, &want) {
+			t.Fatalf("err = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("accepts arrays for repeatable flags", func(t *testing.T) {
+		var set flagr.Set
+		val := flagr.Add(&set, "my-flag-name", flagr.Ints(), "")
+		err := set.Parse(
+			nil,
+			file.Parse(
+				file.Static("testdata/array.json"),
+				file.Mux{".json": json.Unmarshal},
+				file.WithArrayToScalarError(),
+			),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []int{1, 2}; !reflect.DeepEqual(*val, want) {
+			t.Errorf("val = %v, want %v", *val, want)
+		}
+	})
+}
+
+func TestMappers(t *testing.T) {
+	var set flagr.Set
+	direct := flagr.Add(&set, "my-flag-name", flagr.String(""), "")
+	mapped := flagr.Add(&set, "mapped", flagr.String(""), "")
+	missing := flagr.Add(&set, "missing", flagr.String("default"), "")
+
+	err := set.Parse(
+		nil,
+		file.Parse(
+			file.Static("testdata/barebones.json"),
+			file.Mux{".json": json.Unmarshal},
+			file.WithMappers(
+				file.NoopMapper,
+				func(flagName string) file.KeyPath {
+					if flagName == "mapped" {
+						return "my-flag-name"
+					}
+					return ""
+				},
+			),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "asd"; *direct != want {
+		t.Errorf("direct = %q, want %q", *direct, want)
+	}
+	if want := "asd"; *mapped != want {
+		t.Errorf("mapped = %q, want %q", *mapped, want)
+	}
+	if want := "default"; *missing != want {
+		t.Errorf("missing = %q, want %q", *missing, want)
+	}
+}
+
+func TestTableMapper(t *testing.T) {
+	mapper := file.TableMapper(map[string]file.KeyPath{
+		"http-addr": "api.http.address",
+		"db":        "database.url",
+	})
+
+	tests := map[string]file.KeyPath{
+		"http-addr": "api.http.address",
+		"db":        "database.url",
+		"unmapped":  "unmapped",
+		"a.b":       "a.b",
+	}
+	for name, want := range tests {
+		if got := mapper(name); got != want {
+			t.Errorf("mapper(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestReportUnused(t *testing.T) {
+	t.Setenv("APP_ADDR", "env-addr")
+	t.Setenv("APP_STRAY", "1")
+
+	fsys := fstest.MapFS{
+		"cfg.json": &fstest.MapFile{Data: []byte(`{"addr": "file-addr", "db": {"url": "x", "pool": 2}, "typo": true}`)},
+	}
+
+	var set flagr.Set
+	set.SetOutput(io.Discard)
+	addr := flagr.Add(&set, "addr", flagr.String(""), "")
+	dbURL := flagr.Add(&set, "db.url", flagr.String(""), "")
+
+	parsers := []flagr.Parser{
+		env.Parse(env.WithPrefix("app"), env.WithReportUnused()),
+		file.Parse(file.Static("cfg.json"), file.Mux{".json": json.Unmarshal}, file.WithFS(fsys), file.WithReportUnused()),
+	}
+
+	if err := set.Parse(nil, parsers...); err != nil {
+		t.Fatalf("Parse() err = %v, want nil", err)
+	}
+
+	err := set.ParseStrict(nil, parsers...)
+	if !errors.Is(err, flagr.ErrUnused) {
+		t.Fatalf("ParseStrict() err = %v, want %v", err, flagr.ErrUnused)
+	}
+	if want := "unused values: env: APP_STRAY, file[cfg.json]: db.pool, file[cfg.json]: typo"; err.Error() != want {
+		t.Errorf("ParseStrict() err = %q, want %q", err, want)
+	}
+
+	if want := "env-addr"; *addr != want {
+		t.Errorf("addr = %q, want %q", *addr, want)
+	}
+	if want := "x"; *dbURL != want {
+		t.Errorf("db.url = %q, want %q", *dbURL, want)
+	}
+}
+
+func TestVersionKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "supported", data: `{"meta": {"version": 2}, "addr": "file"}`},
+		{name: "unsupported", data: `{"meta": {"version": 3}, "addr": "file"}`, wantErr: `file: unsupported version "3" at path "meta.version", must be one of: 1, 2`},
+		{name: "missing", data: `{"addr": "file"}`, wantErr: `file: missing version at path "meta.version"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"cfg.json": &fstest.MapFile{Data: []byte(tt.data)},
+			}
+
+			var set flagr.Set
+			set.SetOutput(io.Discard)
+			addr := flagr.Add(&set, "addr", flagr.String(""), "")
+			err := set.ParseStrict(nil, file.Parse(
+				file.Static("cfg.json"),
+				file.Mux{".json": json.Unmarshal},
+				file.WithFS(fsys),
+				file.WithReportUnused(),
+				file.WithVersionKey("meta.version", []string{"1", "2"}, nil),
+			))
+
+			if tt.wantErr != "" {
+				var verr file.ErrVersion
+				if !errors.As(err, &verr) {
+					t.Fatalf("err = %v, want ErrVersion", err)
+				}
+				if err.Error() != tt.wantErr {
+					t.Errorf("err = %q, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "file"; *addr != want {
+				t.Errorf("addr = %q, want %q", *addr, want)
+			}
+		})
+	}
+}
+
+func TestValueTransform(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cfg.json": &fstest.MapFile{Data: []byte(`{"level": "WARN", "tags": ["A", "B"]}`)},
+	}
+	lower := func(key file.KeyPath, raw string) (string, error) {
+		return strings.ToLower(raw), nil
+	}
+
+	var set flagr.Set
+	set.SetOutput(io.Discard)
+	level := flagr.Add(&set, "level", flagr.DynamicEnum("info", func() []string { return []string{"debug", "info", "warn"} }), "")
+	tags := flagr.Add(&set, "tags", flagr.Strings(), "")
+	err := set.Parse(nil, file.Parse(
+		file.Static("cfg.json"),
+		file.Mux{".json": json.Unmarshal},
+		file.WithFS(fsys),
+		file.WithValueTransform(lower),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "warn"; *level != want {
+		t.Errorf("level = %q, want %q", *level, want)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, *tags); diff != "" {
+		t.Errorf("tags mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		flagr.Add(&set, "level", flagr.String(""), "")
+		err := set.Parse(nil, file.Parse(
+			file.Static("cfg.json"),
+			file.Mux{".json": json.Unmarshal},
+			file.WithFS(fsys),
+			file.WithValueTransform(func(key file.KeyPath, raw string) (string, error) {
+				return "", errors.New("nope")
+			}),
+		))
+
+		var verr file.ErrVal
+		if !errors.As(err, &verr) {
+			t.Fatalf("err = %v, want ErrVal", err)
+		}
+		if want := `file: invalid value of type string for path "level": nope`; err.Error() != want {
+			t.Errorf("err = %q, want %q", err, want)
+		}
+	})
+}
+
+func TestMultiDoc(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cfg.yaml": &fstest.MapFile{Data: []byte("name=first\nserver.port=80\n---\nname=second\nserver.host=example.com\n")},
+	}
+	// a stub multi document decoder, each document is a properties file
+	multi := func(data []byte, v interface{}) error {
+		docs := v.(*[]map[string]any)
+		for _, doc := range strings.Split(string(data), "---\n") {
+			var m map[string]any
+			if err := file.Properties([]byte(doc), &m); err != nil {
+				return err
+			}
+			*docs = append(*docs, m)
+		}
+		return nil
+	}
+
+	for _, tt := range []struct {
+		name     string
+		selector func([]map[string]any) (map[string]any, error)
+		want     []string
+	}{
+		{"second", file.SelectDocument(1), []string{"second", "", "example.com"}},
+		{"merge", file.MergeDocuments, []string{"second", "80", "example.com"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var set flagr.Set
+			set.SetOutput(io.Discard)
+			name := flagr.Add(&set, "name", flagr.String(""), "")
+			port := flagr.Add(&set, "server.port", flagr.String(""), "")
+			host := flagr.Add(&set, "server.host", flagr.String(""), "")
+			err := set.Parse(nil, file.Parse(
+				file.Static("cfg.yaml"),
+				file.Mux{".yaml": multi},
+				file.WithFS(fsys),
+				file.WithMultiDoc(tt.selector),
+			))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, []string{*name, *port, *host}); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("out of range", func(t *testing.T) {
+		var set flagr.Set
+		set.SetOutput(io.Discard)
+		err := set.Parse(nil, file.Parse(
+			file.Static("cfg.yaml"),
+			file.Mux{".yaml": multi},
+			file.WithFS(fsys),
+			file.WithMultiDoc(file.SelectDocument(2)),
+		))
+		if want := "file: unable to decode: document 2 not found, got 2 documents"; err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	})
+}
+
+func TestSupportedExtensions(t *testing.T) {
+	mux := file.Mux{
+		".yml":        json.Unmarshal,
+		".json":       json.Unmarshal,
+		".properties": file.Properties,
+		".yaml":       json.Unmarshal,
+	}
+	want := []file.Extension{".json", ".properties", ".yaml", ".yml"}
+	if diff := cmp.Diff(want, file.SupportedExtensions(mux)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	var set flagr.Set
+	err := set.Parse(nil, file.Parse(file.Static("cfg.toml"), mux, file.WithFS(fstest.MapFS{
+		"cfg.toml": &fstest.MapFile{},
+	})))
+	if want := `file: unsupported extension ".toml", must be one of: .json, .properties, .yaml, .yml`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	t.Setenv("APP_FROM_ENV", "env")
+	t.Setenv("APP_FROM_CLI", "env")
+
+	fsys := fstest.MapFS{
+		"cfg.json": &fstest.MapFile{Data: []byte(`{"from-env": "file", "from-cli": "file", "from-file": "file"}`)},
+	}
+
+	var set flagr.Set
+	flagr.Add(&set, "from-env", flagr.String(""), "")
+	flagr.Add(&set, "from-cli", flagr.String(""), "")
+	flagr.Add(&set, "from-file", flagr.String(""), "")
+	flagr.Add(&set, "from-default", flagr.String("default"), "")
+
+	err := set.Parse(
+		[]string{"-from-cli", "cli"},
+		env.Parse(env.WithPrefix("app")),
+		file.Parse(file.Static("cfg.json"), file.Mux{".json": json.Unmarshal}, file.WithFS(fsys)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]flagr.FlagProvenance{
+		"from-env":     {Source: "env: APP_FROM_ENV", Value: "env"},
+		"from-cli":     {Source: flagr.SourceFlags, Value: "cli"},
+		"from-file":    {Source: "file[cfg.json]: from-file", Value: "file"},
+		"from-default": {Source: flagr.SourceDefaultVal, Value: "default"},
+	}
+	if diff := cmp.Diff(want, set.Provenance()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	wantInfo := map[string]flagr.SourceInfo{
+		"from-env":     {Kind: "env", Detail: "APP_FROM_ENV"},
+		"from-cli":     {Kind: "flags"},
+		"from-file":    {Kind: "file", Detail: "from-file"},
+		"from-default": {Kind: "default"},
+	}
+	for name, want := range wantInfo {
+		got, ok := set.SourceInfo(name)
+		if !ok {
+			t.Errorf("SourceInfo(%q) not found", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("SourceInfo(%q) = %+v, want %+v", name, got, want)
+		}
+	}
+	if _, ok := set.SourceInfo("nope"); ok {
+		t.Errorf("SourceInfo(%q) found, want not found", "nope")
+	}
+}
+
 func TestFlatJson(t *testing.T) {
 	var set flagr.Set
 	flags, _ := testflags.Make(&set, "")
@@ -272,58 +727,95 @@ func TestFlatJson(t *testing.T) {
 		t.Fatal(err)
 	}
 	want := testflags.Flags{
-		Int:             ptr(int(10)),
-		Ints:            ptr([]int{10, 20}),
-		Int8:            ptr(int8(10)),
-		Int8s:           ptr([]int8{10, 20}),
-		Int16:           ptr(int16(10)),
-		Int16s:          ptr([]int16{10, 20}),
-		Int32:           ptr(int32(10)),
-		Int32s:          ptr([]int32{10, 20}),
-		Int64:           ptr(int64(10)),
-		Int64s:          ptr([]int64{10, 20}),
-		Uint:            ptr(uint(10)),
-		Uints:           ptr([]uint{10, 20}),
-		Uint8:           ptr(uint8(10)),
-		Uint8s:          ptr([]uint8{10, 20}),
-		Uint16:          ptr(uint16(10)),
-		Uint16s:         ptr([]uint16{10, 20}),
-		Uint32:          ptr(uint32(10)),
-		Uint32s:         ptr([]uint32{10, 20}),
-		Uint64:          ptr(uint64(10)),
-		Uint64s:         ptr([]uint64{10, 20}),
-		Float32:         ptr(float32(1.0)),
-		Float32s:        ptr([]float32{1.0, 2.0}),
-		Float64:         ptr(float64(1.0)),
-		Float64s:        ptr([]float64{1.0, 2.0}),
-		Complex64:       ptr(complex64(1i)),
-		Complex64s:      ptr([]complex64{1i, 2i}),
-		Complex128:      ptr(complex128(1i)),
-		Complex128s:     ptr([]complex128{1i, 2i}),
-		Bool:            ptr(false),
-		Bools:           ptr([]bool{false, true}),
-		String:          ptr("qwe"),
-		Strings:         ptr([]string{"qwe", "zxc"}),
-		Duration:        ptr(1 * time.Second),
-		Durations:       ptr([]time.Duration{1 * time.Second, 2 * time.Second}),
-		Time:            ptr(testflags.MustTime("4242-02-25")),
-		MustTime:        ptr(testflags.MustTime("4242-02-25")),
-		Times:           ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
-		MustTimes:       ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
-		URL:             ptr(testflags.MustURL("https://go.devs")),
-		MustURL:         ptr(testflags.MustURL("https://go.devs")),
-		URLs:            ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
-		MustURLs:        ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
-		IPAddr:          ptr(netip.MustParseAddr("127.0.0.2")),
-		MustIPAddr:      ptr(netip.MustParseAddr("127.0.0.2")),
-		IPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
-		MustIPAddrs:     ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
-		IPAddrPort:      ptr(netip.MustParseAddrPort("127.0.0.1:81")),
-		MustIPAddrPort:  ptr(netip.MustParseAddrPort("127.0.0.1:81")),
-		IPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
-		MustIPAddrPorts: ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
-	}
-	if diff := cmp.Diff(want, flags, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{})); diff != "" {
+		Int:                 ptr(int(10)),
+		Ints:                ptr([]int{10, 20}),
+		Int8:                ptr(int8(10)),
+		Int8s:               ptr([]int8{10, 20}),
+		Int16:               ptr(int16(10)),
+		Int16s:              ptr([]int16{10, 20}),
+		Int32:               ptr(int32(10)),
+		Int32s:              ptr([]int32{10, 20}),
+		Int64:               ptr(int64(10)),
+		Int64s:              ptr([]int64{10, 20}),
+		Uint:                ptr(uint(10)),
+		Uints:               ptr([]uint{10, 20}),
+		Uint8:               ptr(uint8(10)),
+		Uint8s:              ptr([]uint8{10, 20}),
+		Uint16:              ptr(uint16(10)),
+		Uint16s:             ptr([]uint16{10, 20}),
+		Uint32:              ptr(uint32(10)),
+		Uint32s:             ptr([]uint32{10, 20}),
+		Uint64:              ptr(uint64(10)),
+		Uint64s:             ptr([]uint64{10, 20}),
+		Float32:             ptr(float32(1.0)),
+		Float32s:            ptr([]float32{1.0, 2.0}),
+		Float64:             ptr(float64(1.0)),
+		Float64s:            ptr([]float64{1.0, 2.0}),
+		Complex64:           ptr(complex64(1i)),
+		Complex64s:          ptr([]complex64{1i, 2i}),
+		Complex128:          ptr(complex128(1i)),
+		Complex128s:         ptr([]complex128{1i, 2i}),
+		Bool:                ptr(false),
+		Bools:               ptr([]bool{false, true}),
+		String:              ptr("qwe"),
+		Strings:             ptr([]string{"qwe", "zxc"}),
+		Duration:            ptr(1 * time.Second),
+		Durations:           ptr([]time.Duration{1 * time.Second, 2 * time.Second}),
+		Time:                ptr(testflags.MustTime("4242-02-25")),
+		MustTime:            ptr(testflags.MustTime("4242-02-25")),
+		Times:               ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
+		MustTimes:           ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
+		URL:                 ptr(testflags.MustURL("https://go.devs")),
+		MustURL:             ptr(testflags.MustURL("https://go.devs")),
+		URLs:                ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
+		MustURLs:            ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
+		IPAddr:              ptr(netip.MustParseAddr("127.0.0.2")),
+		MustIPAddr:          ptr(netip.MustParseAddr("127.0.0.2")),
+		IPAddrs:             ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
+		MustIPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
+		IPAddrPort:          ptr(netip.MustParseAddrPort("127.0.0.1:81")),
+		MustIPAddrPort:      ptr(netip.MustParseAddrPort("127.0.0.1:81")),
+		IPAddrPorts:         ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
+		MustIPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
+		MAC:                 ptr(testflags.MustMAC("11:22:33:44:55:66")),
+		MustMAC:             ptr(testflags.MustMAC("11:22:33:44:55:66")),
+		MACs:                ptr([]net.HardwareAddr{testflags.MustMAC("11:22:33:44:55:66"), testflags.MustMAC("11:22:33:44:55:67")}),
+		MustMACs:            ptr([]net.HardwareAddr{testflags.MustMAC("11:22:33:44:55:66"), testflags.MustMAC("11:22:33:44:55:67")}),
+		IntRanges:           ptr([]int{1, 2, 4}),
+		BoolOrDuration:      ptr(flagr.Toggle{Enabled: true, TTL: time.Hour}),
+		FeatureSet:          ptr(map[string]bool{"asd": false, "dsa": true}),
+		PrefixSet:           ptr([]netip.Prefix{netip.MustParsePrefix("10.2.0.0/16"), netip.MustParsePrefix("10.3.0.0/16")}),
+		KeyValues:           ptr([]flagr.KeyValue{{Key: "qwe", Value: "a=b"}, {Key: "qwe", Value: "c"}}),
+		StringValidated:     ptr("qwe"),
+		StringsValidated:    ptr([]string{"qwe", "zxc"}),
+		SemVer:              ptr(flagr.Version{Major: 4, Minor: 2, Patch: 1}),
+		SemVers:             ptr([]flagr.Version{{Major: 4, Minor: 2, Patch: 1}, {Major: 2, Minor: 4, Patch: 1}}),
+		TimeOfDay:           ptr(flagr.Clock{Hour: 4, Minute: 20}),
+		TimesOfDay:          ptr([]flagr.Clock{{Hour: 4, Minute: 20}, {Hour: 20, Minute: 4}}),
+		EnumSet:             ptr([]string{"dsa", "qwe", "dsa"}),
+		EnumSetDedupe:       ptr([]string{"dsa", "qwe"}),
+		StringOrFile:        ptr("qwe"),
+		Rate:                ptr(float64(1024)),
+		Seconds:             ptr(2500 * time.Millisecond),
+		SecondsList:         ptr([]time.Duration{time.Second, 2 * time.Minute}),
+		Schema:              ptr([]flagr.Column{{Name: "qwe", Type: "int"}, {Name: "zxc", Type: "time"}}),
+		SI:                  ptr(float64(3e6)),
+		PortRanges:          ptr([]flagr.PortRange{{Lo: 81, Hi: 81}, {Lo: 9000, Hi: 9100}}),
+		Distribution:        ptr([]float64{0.5, 0.25, 0.25}),
+		Cron:                ptr(flagr.CronExpr{Raw: "30 2 1 * *"}),
+		Tristate:            ptr(flagr.Auto),
+		Indirect:            ptr(flagr.IndirectValue{Origin: "literal", Raw: "zxc", Value: "zxc"}),
+		Quantity:            ptr(flagr.QuantityValue{Value: 1.5, Number: "1.5"}),
+		Ordering:            ptr([]string{"dsa", "qwe", "asd"}),
+		IntStep:             ptr(int(24)),
+		UintStep:            ptr(uint(24)),
+		IntBase:             ptr(int(43)),
+		UintBase:            ptr(uint(43)),
+		DurationDefaultUnit: ptr(90 * time.Second),
+		Tuple3:              ptr(flagr.Triple[int, int, string]{First: 2, Second: 4, Third: "zxc"}),
+		Fields:              ptr(map[string]any{"asd": 2, "dsa": "zxc"}),
+	}
+	if diff := cmp.Diff(want, flags, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{}, netip.Prefix{}, flagr.CronExpr{})); diff != "" {
 		t.Errorf("mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -342,58 +834,95 @@ func TestNestedJson(t *testing.T) {
 		t.Fatal(err)
 	}
 	want := testflags.Flags{
-		Int:             ptr(int(10)),
-		Ints:            ptr([]int{10, 20}),
-		Int8:            ptr(int8(10)),
-		Int8s:           ptr([]int8{10, 20}),
-		Int16:           ptr(int16(10)),
-		Int16s:          ptr([]int16{10, 20}),
-		Int32:           ptr(int32(10)),
-		Int32s:          ptr([]int32{10, 20}),
-		Int64:           ptr(int64(10)),
-		Int64s:          ptr([]int64{10, 20}),
-		Uint:            ptr(uint(10)),
-		Uints:           ptr([]uint{10, 20}),
-		Uint8:           ptr(uint8(10)),
-		Uint8s:          ptr([]uint8{10, 20}),
-		Uint16:          ptr(uint16(10)),
-		Uint16s:         ptr([]uint16{10, 20}),
-		Uint32:          ptr(uint32(10)),
-		Uint32s:         ptr([]uint32{10, 20}),
-		Uint64:          ptr(uint64(10)),
-		Uint64s:         ptr([]uint64{10, 20}),
-		Float32:         ptr(float32(1.0)),
-		Float32s:        ptr([]float32{1.0, 2.0}),
-		Float64:         ptr(float64(1.0)),
-		Float64s:        ptr([]float64{1.0, 2.0}),
-		Complex64:       ptr(complex64(1i)),
-		Complex64s:      ptr([]complex64{1i, 2i}),
-		Complex128:      ptr(complex128(1i)),
-		Complex128s:     ptr([]complex128{1i, 2i}),
-		Bool:            ptr(false),
-		Bools:           ptr([]bool{false, true}),
-		String:          ptr("qwe"),
-		Strings:         ptr([]string{"qwe", "zxc"}),
-		Duration:        ptr(1 * time.Second),
-		Durations:       ptr([]time.Duration{1 * time.Second, 2 * time.Second}),
-		Time:            ptr(testflags.MustTime("4242-02-25")),
-		MustTime:        ptr(testflags.MustTime("4242-02-25")),
-		Times:           ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
-		MustTimes:       ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
-		URL:             ptr(testflags.MustURL("https://go.devs")),
-		MustURL:         ptr(testflags.MustURL("https://go.devs")),
-		URLs:            ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
-		MustURLs:        ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
-		IPAddr:          ptr(netip.MustParseAddr("127.0.0.2")),
-		MustIPAddr:      ptr(netip.MustParseAddr("127.0.0.2")),
-		IPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
-		MustIPAddrs:     ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
-		IPAddrPort:      ptr(netip.MustParseAddrPort("127.0.0.1:81")),
-		MustIPAddrPort:  ptr(netip.MustParseAddrPort("127.0.0.1:81")),
-		IPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
-		MustIPAddrPorts: ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
-	}
-	if diff := cmp.Diff(want, flags, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{})); diff != "" {
+		Int:                 ptr(int(10)),
+		Ints:                ptr([]int{10, 20}),
+		Int8:                ptr(int8(10)),
+		Int8s:               ptr([]int8{10, 20}),
+		Int16:               ptr(int16(10)),
+		Int16s:              ptr([]int16{10, 20}),
+		Int32:               ptr(int32(10)),
+		Int32s:              ptr([]int32{10, 20}),
+		Int64:               ptr(int64(10)),
+		Int64s:              ptr([]int64{10, 20}),
+		Uint:                ptr(uint(10)),
+		Uints:               ptr([]uint{10, 20}),
+		Uint8:               ptr(uint8(10)),
+		Uint8s:              ptr([]uint8{10, 20}),
+		Uint16:              ptr(uint16(10)),
+		Uint16s:             ptr([]uint16{10, 20}),
+		Uint32:              ptr(uint32(10)),
+		Uint32s:             ptr([]uint32{10, 20}),
+		Uint64:              ptr(uint64(10)),
+		Uint64s:             ptr([]uint64{10, 20}),
+		Float32:             ptr(float32(1.0)),
+		Float32s:            ptr([]float32{1.0, 2.0}),
+		Float64:             ptr(float64(1.0)),
+		Float64s:            ptr([]float64{1.0, 2.0}),
+		Complex64:           ptr(complex64(1i)),
+		Complex64s:          ptr([]complex64{1i, 2i}),
+		Complex128:          ptr(complex128(1i)),
+		Complex128s:         ptr([]complex128{1i, 2i}),
+		Bool:                ptr(false),
+		Bools:               ptr([]bool{false, true}),
+		String:              ptr("qwe"),
+		Strings:             ptr([]string{"qwe", "zxc"}),
+		Duration:            ptr(1 * time.Second),
+		Durations:           ptr([]time.Duration{1 * time.Second, 2 * time.Second}),
+		Time:                ptr(testflags.MustTime("4242-02-25")),
+		MustTime:            ptr(testflags.MustTime("4242-02-25")),
+		Times:               ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
+		MustTimes:           ptr([]time.Time{testflags.MustTime("4242-02-25"), testflags.MustTime("2000-02-25")}),
+		URL:                 ptr(testflags.MustURL("https://go.devs")),
+		MustURL:             ptr(testflags.MustURL("https://go.devs")),
+		URLs:                ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
+		MustURLs:            ptr([]*url.URL{testflags.MustURL("https://go.devs"), testflags.MustURL("https://go.devs/tour/")}),
+		IPAddr:              ptr(netip.MustParseAddr("127.0.0.2")),
+		MustIPAddr:          ptr(netip.MustParseAddr("127.0.0.2")),
+		IPAddrs:             ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
+		MustIPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.3")}),
+		IPAddrPort:          ptr(netip.MustParseAddrPort("127.0.0.1:81")),
+		MustIPAddrPort:      ptr(netip.MustParseAddrPort("127.0.0.1:81")),
+		IPAddrPorts:         ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
+		MustIPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:81"), netip.MustParseAddrPort("127.0.0.1:82")}),
+		MAC:                 ptr(testflags.MustMAC("11:22:33:44:55:66")),
+		MustMAC:             ptr(testflags.MustMAC("11:22:33:44:55:66")),
+		MACs:                ptr([]net.HardwareAddr{testflags.MustMAC("11:22:33:44:55:66"), testflags.MustMAC("11:22:33:44:55:67")}),
+		MustMACs:            ptr([]net.HardwareAddr{testflags.MustMAC("11:22:33:44:55:66"), testflags.MustMAC("11:22:33:44:55:67")}),
+		IntRanges:           ptr([]int{1, 2, 4}),
+		BoolOrDuration:      ptr(flagr.Toggle{Enabled: true, TTL: time.Hour}),
+		FeatureSet:          ptr(map[string]bool{"asd": false, "dsa": true}),
+		PrefixSet:           ptr([]netip.Prefix{netip.MustParsePrefix("10.2.0.0/16"), netip.MustParsePrefix("10.3.0.0/16")}),
+		KeyValues:           ptr([]flagr.KeyValue{{Key: "qwe", Value: "a=b"}, {Key: "qwe", Value: "c"}}),
+		StringValidated:     ptr("qwe"),
+		StringsValidated:    ptr([]string{"qwe", "zxc"}),
+		SemVer:              ptr(flagr.Version{Major: 4, Minor: 2, Patch: 1}),
+		SemVers:             ptr([]flagr.Version{{Major: 4, Minor: 2, Patch: 1}, {Major: 2, Minor: 4, Patch: 1}}),
+		TimeOfDay:           ptr(flagr.Clock{Hour: 4, Minute: 20}),
+		TimesOfDay:          ptr([]flagr.Clock{{Hour: 4, Minute: 20}, {Hour: 20, Minute: 4}}),
+		EnumSet:             ptr([]string{"dsa", "qwe", "dsa"}),
+		EnumSetDedupe:       ptr([]string{"dsa", "qwe"}),
+		StringOrFile:        ptr("qwe"),
+		Rate:                ptr(float64(1024)),
+		Seconds:             ptr(2500 * time.Millisecond),
+		SecondsList:         ptr([]time.Duration{time.Second, 2 * time.Minute}),
+		Schema:              ptr([]flagr.Column{{Name: "qwe", Type: "int"}, {Name: "zxc", Type: "time"}}),
+		SI:                  ptr(float64(3e6)),
+		PortRanges:          ptr([]flagr.PortRange{{Lo: 81, Hi: 81}, {Lo: 9000, Hi: 9100}}),
+		Distribution:        ptr([]float64{0.5, 0.25, 0.25}),
+		Cron:                ptr(flagr.CronExpr{Raw: "30 2 1 * *"}),
+		Tristate:            ptr(flagr.Auto),
+		Indirect:            ptr(flagr.IndirectValue{Origin: "literal", Raw: "zxc", Value: "zxc"}),
+		Quantity:            ptr(flagr.QuantityValue{Value: 1.5, Number: "1.5"}),
+		Ordering:            ptr([]string{"dsa", "qwe", "asd"}),
+		IntStep:             ptr(int(24)),
+		UintStep:            ptr(uint(24)),
+		IntBase:             ptr(int(43)),
+		UintBase:            ptr(uint(43)),
+		DurationDefaultUnit: ptr(90 * time.Second),
+		Tuple3:              ptr(flagr.Triple[int, int, string]{First: 2, Second: 4, Third: "zxc"}),
+		Fields:              ptr(map[string]any{"asd": 2, "dsa": "zxc"}),
+	}
+	if diff := cmp.Diff(want, flags, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{}, netip.Prefix{}, flagr.CronExpr{})); diff != "" {
 		t.Errorf("mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -0,0 +1,181 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var _ DecoderFunc = Properties
+
+// Properties is a [DecoderFunc] that decodes Java style .properties files into
+// a *map[string]any.
+//
+// Both "key=value" and "key:value" (as well as "key value") are supported, lines
+// starting with "#" or "!" are comments and lines ending in a "\" are continued
+// in the next line.
+//
+// Keys are split on [KeyPathSeparator] producing nested maps, so that
+//
+//	server.port=8080
+//
+// is equivalent to the json
+//
+//	{
+//		"server": {
+//			"port": "8080"
+//		}
+//	}
+//
+// All values are decoded as strings.
+func Properties(data []byte, v interface{}) error {
+	dst, ok := v.(*map[string]any)
+	if !ok {
+		return fmt.Errorf("properties: cannot decode into %T, must be *map[string]any", v)
+	}
+	if *dst == nil {
+		*dst = make(map[string]any)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var lineno int
+	var logical strings.Builder
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		if continues(line) {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+
+		key, val, err := splitProperty(logical.String())
+		logical.Reset()
+		if err != nil {
+			return fmt.Errorf("properties: line %d: %w", lineno, err)
+		}
+		if err := assignProperty(*dst, key, val); err != nil {
+			return fmt.Errorf("properties: line %d: %w", lineno, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("properties: %w", err)
+	}
+
+	if logical.Len() > 0 {
+		key, val, err := splitProperty(logical.String())
+		if err != nil {
+			return fmt.Errorf("properties: line %d: %w", lineno, err)
+		}
+		if err := assignProperty(*dst, key, val); err != nil {
+			return fmt.Errorf("properties: line %d: %w", lineno, err)
+		}
+	}
+
+	return nil
+}
+
+// continues reports whether line ends in an odd number of backslashes.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func splitProperty(line string) (key, value string, err error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '=' || line[i] == ':' || line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+			end = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if len(rest) > 0 && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	if key, err = unescapeProperty(line[:end]); err != nil {
+		return "", "", err
+	}
+	if value, err = unescapeProperty(rest); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid unicode escape in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape in %q", s)
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func assignProperty(root map[string]any, key, value string) error {
+	segments := KeyPath(key).Split()
+	m := root
+	for i, segment := range segments[:len(segments)-1] {
+		switch child := m[segment].(type) {
+		case nil:
+			next := make(map[string]any)
+			m[segment] = next
+			m = next
+		case map[string]any:
+			m = child
+		default:
+			return fmt.Errorf("key %q conflicts with %q", key, strings.Join(segments[:i+1], KeyPathSeparator))
+		}
+	}
+
+	last := segments[len(segments)-1]
+	if _, ok := m[last].(map[string]any); ok {
+		return fmt.Errorf("key %q conflicts with a nested key", key)
+	}
+	m[last] = value
+	return nil
+}
@@ -0,0 +1,83 @@
+package file_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flga/flagr"
+	"github.com/flga/flagr/file"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProperties(t *testing.T) {
+	t.Run("decodes nested keys", func(t *testing.T) {
+		var set flagr.Set
+		port := flagr.Add(&set, "server.port", flagr.Int(0), "")
+		host := flagr.Add(&set, "server.host", flagr.String(""), "")
+		name := flagr.Add(&set, "server.name", flagr.String(""), "")
+		urls := flagr.Add(&set, "db.urls", flagr.Strings(), "")
+		greeting := flagr.Add(&set, "greeting", flagr.String(""), "")
+
+		err := set.Parse(
+			nil,
+			file.Parse(
+				file.Static("testdata/nested.properties"),
+				file.Mux{".properties": file.Properties},
+			),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := 8080; *port != want {
+			t.Errorf("port = %v, want %v", *port, want)
+		}
+		if want := "0.0.0.0"; *host != want {
+			t.Errorf("host = %q, want %q", *host, want)
+		}
+		if want := "my server"; *name != want {
+			t.Errorf("name = %q, want %q", *name, want)
+		}
+		if want := []string{"postgres://a,postgres://b"}; !reflect.DeepEqual(*urls, want) {
+			t.Errorf("urls = %q, want %q", *urls, want)
+		}
+		if want := "café"; *greeting != want {
+			t.Errorf("greeting = %q, want %q", *greeting, want)
+		}
+	})
+
+	t.Run("produces nested maps", func(t *testing.T) {
+		var got map[string]any
+		if err := file.Properties([]byte("a.b.c=1\na.d=2\ne=3"), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{
+			"a": map[string]any{
+				"b": map[string]any{"c": "1"},
+				"d": "2",
+			},
+			"e": "3",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("fails on conflicting keys", func(t *testing.T) {
+		var got map[string]any
+		if err := file.Properties([]byte("a=1\na.b=2"), &got); err == nil {
+			t.Fatal("err is nil")
+		}
+		got = nil
+		if err := file.Properties([]byte("a.b=1\na=2"), &got); err == nil {
+			t.Fatal("err is nil")
+		}
+	})
+
+	t.Run("fails on unsupported targets", func(t *testing.T) {
+		var got map[string]string
+		if err := file.Properties([]byte("a=1"), &got); err == nil {
+			t.Fatal("err is nil")
+		}
+	})
+}
@@ -2,16 +2,29 @@
 package flagr
 
 import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	stdflag "flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
+	"net"
 	"net/netip"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -31,14 +44,47 @@ const (
 // ErrHelp is the error returned if the -help or -h flag is invoked but no such flag is defined.
 var ErrHelp = stdflag.ErrHelp
 
+// ErrRedefined is the error returned by TryAdd if a flag with the same name already exists.
+var ErrRedefined = errors.New("flag redefined")
+
+// ErrUnused is returned by ParseStrict when a parser reported values that did
+// not match any flag.
+var ErrUnused = errors.New("unused values")
+
+// ErrFrozen is returned when trying to modify a Set after Freeze has been called.
+var ErrFrozen = errors.New("flag set is frozen")
+
 // A Set represents a set of defined flags. The zero value of a Set
 // has no name and has ContinueOnError error handling.
 //
 // Flag names must be unique within a Set. An attempt to define a flag whose
 // name is already in use will cause a panic.
+//
+// Once the Set has been parsed it is safe to call Set, SetDefault, Lookup, Get,
+// GetString, the Visit family, PrintValues, FprintValues, Provenance, SourceInfo,
+// FlagsBySource and WriteINI concurrently. Reading the pointers returned by Add
+// while calling Set is still a data race, use Get or GetString instead.
+//
+// Values are set with the Set locked, so the Set and String methods of a Getter,
+// and any callback they run, must not call methods of the Set that owns it, or
+// they will deadlock.
 type Set struct {
-	fs         *stdflag.FlagSet
-	provideMap map[string]Source
+	once        sync.Once
+	mu          sync.RWMutex
+	docMu       sync.RWMutex // guards annotations and typeNamer, usage is printed with mu held by Parse
+	fs          *stdflag.FlagSet
+	provideMap  map[string]Source
+	infoMap     map[string]SourceInfo
+	annotations map[string]map[string]any
+	unused      []string
+	frozen      bool
+	parseHook   func(stage int, parser Parser, remaining int)
+	warnDefault []string
+	typeNamer   func(*Flag) string
+	tracing     bool
+	trace       []TraceEntry
+	envDefaults map[string]string
+	snapshots   map[string]*snapshot
 }
 
 // Source identifies who set the value for a given flag.
@@ -49,8 +95,24 @@ type Source string
 const (
 	SourceDefaultVal Source = "default"
 	SourceFlags      Source = "flags"
+	SourceEnvDefault Source = "env-default" // A default read from the environment by EnvDefault.
 )
 
+// isDefault reports whether src is one of the sources of default values.
+func isDefault(src Source) bool {
+	return src == SourceDefaultVal || src == SourceEnvDefault
+}
+
+// SourceInfo is a structured counterpart to Source, meant for machine consumption.
+//
+// Kind identifies the type of source, such as "flags", "env" or "file", while
+// Detail identifies where in that source the value came from, such as the
+// variable name. The format of Detail depends on the Kind.
+type SourceInfo struct {
+	Kind   string
+	Detail string
+}
+
 // NewSet returns a new, empty flag set with the specified name and
 // error handling property. If the name is not empty, it will be printed
 // in the default usage message and in error messages.
@@ -60,24 +122,42 @@ func NewSet(name string, errorHandling ErrorHandling) *Set {
 }
 
 func (set *Set) init() {
-	if set.fs == nil {
-		set.fs = &stdflag.FlagSet{}
-	}
+	set.once.Do(func() {
+		if set.fs == nil {
+			set.fs = &stdflag.FlagSet{}
+		}
 
-	if set.provideMap == nil {
-		set.provideMap = make(map[string]Source)
-	}
+		if set.provideMap == nil {
+			set.provideMap = make(map[string]Source)
+		}
 
-	if set.fs.Usage == nil {
-		set.fs.Usage = func() {
-			if set.fs.Name() == "" {
-				fmt.Fprintf(set.fs.Output(), "Usage:\n")
-			} else {
-				fmt.Fprintf(set.fs.Output(), "Usage of %s:\n", set.fs.Name())
+		if set.infoMap == nil {
+			set.infoMap = make(map[string]SourceInfo)
+		}
+
+		if set.envDefaults == nil {
+			set.envDefaults = make(map[string]string)
+		}
+
+		if set.snapshots == nil {
+			set.snapshots = make(map[string]*snapshot)
+		}
+
+		if set.annotations == nil {
+			set.annotations = make(map[string]map[string]any)
+		}
+
+		if set.fs.Usage == nil {
+			set.fs.Usage = func() {
+				if set.fs.Name() == "" {
+					fmt.Fprintf(set.fs.Output(), "Usage:\n")
+				} else {
+					fmt.Fprintf(set.fs.Output(), "Usage of %s:\n", set.fs.Name())
+				}
+				set.PrintDefaults()
 			}
-			set.fs.PrintDefaults()
 		}
-	}
+	})
 }
 
 // SetUsage overrides the Set's usage func.
@@ -111,16 +191,21 @@ func (set *Set) SetOutput(output io.Writer) { set.init(); set.fs.SetOutput(outpu
 // an error.
 func (set *Set) VisitAll(fn func(*Flag) error) error {
 	set.init()
-	var visitErr error
 
+	// fn is called without holding the lock so that it may call Set
+	set.mu.RLock()
+	var flags []*Flag
 	set.fs.VisitAll(func(f *Flag) {
-		if visitErr != nil {
-			return
-		}
-
-		visitErr = fn(f)
+		flags = append(flags, f)
 	})
-	return visitErr
+	set.mu.RUnlock()
+
+	for _, f := range flags {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Visit visits the flags in lexicographical order, calling fn for each.
@@ -129,15 +214,20 @@ func (set *Set) VisitAll(fn func(*Flag) error) error {
 func (set *Set) Visit(fn func(*Flag) error) error {
 	set.init()
 
-	var visitErr error
+	// fn is called without holding the lock so that it may call Set
+	set.mu.RLock()
+	var flags []*Flag
 	set.fs.Visit(func(f *Flag) {
-		if visitErr != nil {
-			return
-		}
-		visitErr = fn(f)
+		flags = append(flags, f)
 	})
+	set.mu.RUnlock()
 
-	return visitErr
+	for _, f := range flags {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // VisitRemaining visits the flags in lexicographical order, calling fn for each.
@@ -161,18 +251,279 @@ func (set *Set) VisitRemaining(fn func(*Flag) error) error {
 }
 
 // Lookup returns the Flag structure of the named flag, returning nil if none exists.
-func (set *Set) Lookup(name string) *Flag { set.init(); return set.fs.Lookup(name) }
+func (set *Set) Lookup(name string) *Flag {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.fs.Lookup(name)
+}
+
+// Get returns a copy of the current value of the named flag. It returns false if
+// the flag does not exist or if it is not a Getter[T]. Slices and maps are copied
+// as well, but not the values they hold.
+//
+// Unlike dereferencing the pointer returned by Add, it is safe to call Get
+// concurrently with Set.
+func Get[T any](set *Set, name string) (T, bool) {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	var zero T
+	f := set.fs.Lookup(name)
+	if f == nil {
+		return zero, false
+	}
+	g, ok := f.Value.(Getter[T])
+	if !ok {
+		return zero, false
+	}
+	// slices and maps share storage with the flag, which later calls to Set reuse
+	return shallowClone(*g.Val()), true
+}
+
+// GetString returns the current value of the named flag as given by its String
+// method, and whether the flag exists. It is safe to call concurrently with Set.
+func (set *Set) GetString(name string) (string, bool) {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	f := set.fs.Lookup(name)
+	if f == nil {
+		return "", false
+	}
+	return f.Value.String(), true
+}
 
 // Set sets the value of the named flag, annotating it with the given source.
+//
+// The structured source, as reported by SourceInfo, will have src as its Kind
+// and no Detail. Use SetWithInfo to record both.
 func (set *Set) Set(src Source, name, value string) error {
 	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if err := set.checkFrozen(); err != nil {
+		return err
+	}
+	if err := set.fs.Set(name, value); err != nil {
+		return err
+	}
+	set.provideMap[name] = src
+	delete(set.infoMap, name)
+	set.record(name, src)
+	return nil
+}
+
+// SetWithInfo is like Set but it also records a structured source, as reported
+// by SourceInfo. The src string is still used by PrintValues and Provenance.
+func (set *Set) SetWithInfo(src Source, info SourceInfo, name, value string) error {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if err := set.checkFrozen(); err != nil {
+		return err
+	}
 	if err := set.fs.Set(name, value); err != nil {
 		return err
 	}
 	set.provideMap[name] = src
+	set.infoMap[name] = info
+	set.record(name, src)
+	return nil
+}
+
+// SourceInfo returns the structured source of the named flag's value.
+// It returns false if the flag does not exist or has no recorded source yet.
+func (set *Set) SourceInfo(name string) (SourceInfo, bool) {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	if info, ok := set.infoMap[name]; ok {
+		return info, true
+	}
+	src, ok := set.provideMap[name]
+	if !ok {
+		return SourceInfo{}, false
+	}
+	return SourceInfo{Kind: string(src)}, true
+}
+
+// SetDefault sets the value of the named flag as if it was its default value.
+//
+// The value is recorded as coming from SourceDefaultVal, it is reflected in the
+// usage message and it can be overridden by any source, including extra parsers.
+// This allows changing defaults after a flag has been defined without redefining it.
+func (set *Set) SetDefault(name, value string) error {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if err := set.checkFrozen(); err != nil {
+		return err
+	}
+	f := set.fs.Lookup(name)
+	if f == nil {
+		return fmt.Errorf("no such flag -%v", name)
+	}
+	if err := f.Value.Set(value); err != nil {
+		return err
+	}
+	if d, ok := f.Value.(defaulter); ok {
+		d.markDefault()
+	}
+	f.DefValue = f.Value.String()
+	if snap, ok := set.snapshots[name]; ok {
+		snap.save()
+	}
+	set.provideMap[name] = SourceDefaultVal
+	delete(set.infoMap, name)
+	delete(set.envDefaults, name)
+	set.record(name, SourceDefaultVal)
+	return nil
+}
+
+// defaulter is implemented by values that need to know if their current value is a default.
+type defaulter interface {
+	markDefault()
+}
+
+// Reset returns the Set to the state it was in before Parse: every flag is
+// restored to its default value, as given to Add, StructFlag or SetDefault, and
+// all provenance is forgotten. Calling Parse again with the same arguments and
+// parsers yields the same values and provenance as the first time.
+//
+// Flags, annotations, hooks and the trace are kept. The *Flag values previously
+// returned by Lookup are no longer valid, while the pointers returned by Add and
+// StructFlag are.
+func (set *Set) Reset() error {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if err := set.checkFrozen(); err != nil {
+		return err
+	}
+
+	// the std FlagSet has no way to forget which flags were set, so rebuild it
+	fs := stdflag.NewFlagSet(set.fs.Name(), set.fs.ErrorHandling())
+	fs.Usage = set.fs.Usage
+	fs.SetOutput(set.fs.Output())
+	set.fs.VisitAll(func(f *Flag) {
+		if snap, ok := set.snapshots[f.Name]; ok {
+			snap.restore()
+		}
+		if d, ok := f.Value.(defaulter); ok {
+			d.markDefault()
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	set.fs = fs
+
+	set.provideMap = make(map[string]Source)
+	set.infoMap = make(map[string]SourceInfo)
+	set.unused = nil
 	return nil
 }
 
+// snapshot saves and restores the default value of a flag, for Reset.
+type snapshot struct {
+	save    func()
+	restore func()
+}
+
+func newSnapshot[T any](p *T) *snapshot {
+	var def T
+	snap := &snapshot{
+		save:    func() { def = shallowClone(*p) },
+		restore: func() { *p = shallowClone(def) },
+	}
+	snap.save()
+	return snap
+}
+
+// pointerSnapshot is like newSnapshot for values whose type is only known at
+// runtime, such as struct fields. p must be a pointer, as returned by the Get
+// method of most Getters, otherwise it returns nil.
+func pointerSnapshot(p any) *snapshot {
+	rv := reflect.ValueOf(p)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || !rv.Elem().CanSet() {
+		return nil
+	}
+	elem := rv.Elem()
+	def := reflect.New(elem.Type()).Elem()
+	snap := &snapshot{
+		save:    func() { def.Set(shallowCloneValue(elem)) },
+		restore: func() { elem.Set(shallowCloneValue(def)) },
+	}
+	snap.save()
+	return snap
+}
+
+// shallowClone copies the backing storage of slices and maps, which getters
+// reuse when they are set, so that v does not change along with the flag.
+func shallowClone[T any](v T) T {
+	rv := reflect.ValueOf(&v).Elem()
+	rv.Set(shallowCloneValue(rv))
+	return v
+}
+
+func shallowCloneValue(v reflect.Value) reflect.Value {
+	switch {
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	case v.Kind() == reflect.Map && !v.IsNil():
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return c
+	}
+	return v
+}
+
+// Annotate attaches arbitrary metadata to the named flag under the given key,
+// replacing any previous value. Annotations are not used by the Set itself, they
+// are an extension point for tooling such as documentation generators.
+func (set *Set) Annotate(name, key string, value any) {
+	set.init()
+	set.docMu.Lock()
+	defer set.docMu.Unlock()
+	if set.annotations[name] == nil {
+		set.annotations[name] = make(map[string]any)
+	}
+	set.annotations[name][key] = value
+}
+
+// Annotation returns the value stored by Annotate for the named flag and key, and
+// whether it was found.
+func (set *Set) Annotation(name, key string) (any, bool) {
+	set.init()
+	set.docMu.RLock()
+	defer set.docMu.RUnlock()
+	v, ok := set.annotations[name][key]
+	return v, ok
+}
+
+const (
+	exampleAnnotation  = "flagr:example"
+	requiredAnnotation = "flagr:required"
+)
+
+// SetExample sets an example value for the named flag, which is shown by PrintDefaultsVerbose.
+// It is stored as an annotation, see Annotate.
+func (set *Set) SetExample(name, example string) {
+	set.Annotate(name, exampleAnnotation, example)
+}
+
+// MarkRequired marks the named flag as required in the output of JSONSchema.
+// It is stored as an annotation, see Annotate, and it is not enforced by Parse.
+func (set *Set) MarkRequired(name string) {
+	set.Annotate(name, requiredAnnotation, true)
+}
+
 // UnquoteUsage extracts a back-quoted name from the usage
 // string for a flag and returns it and the un-quoted usage.
 // Given "a `name` to show" it returns ("name", "a name to show").
@@ -182,18 +533,122 @@ func UnquoteUsage(flag *Flag) (name string, usage string) {
 	return stdflag.UnquoteUsage(flag)
 }
 
+// GoTypeName is a type namer, for use with SetTypeNamer, that names the type of
+// the flag's value as given by the Val method of its Getter, such as
+// "netip.AddrPort" or "[]time.Duration". It returns an empty string for boolean
+// flags and for values that are not a Getter.
+func GoTypeName(f *Flag) string {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return ""
+	}
+	val := reflect.ValueOf(f.Value).MethodByName("Val")
+	if !val.IsValid() || val.Type().NumIn() != 0 || val.Type().NumOut() != 1 || val.Type().Out(0).Kind() != reflect.Pointer {
+		return ""
+	}
+	return val.Type().Out(0).Elem().String()
+}
+
+// SetTypeNamer makes PrintDefaults and PrintDefaultsVerbose use fn to name the
+// value of each flag in the usage message, instead of the guess made by UnquoteUsage,
+// which is "value" for most flagr types. See GoTypeName.
+//
+// Names given with back quotes in the usage string take precedence, and so does
+// the guess made by UnquoteUsage if fn returns an empty string.
+func (set *Set) SetTypeNamer(fn func(f *Flag) string) {
+	set.init()
+	set.docMu.Lock()
+	defer set.docMu.Unlock()
+	set.typeNamer = fn
+}
+
 // PrintDefaults prints, to standard error unless configured otherwise, the
 // default values of all defined command-line flags in the set. See the
 // documentation for the global function PrintDefaults for more information.
-func (set *Set) PrintDefaults() { set.init(); set.fs.PrintDefaults() }
+func (set *Set) PrintDefaults() {
+	set.init()
+	typeNamer := set.getTypeNamer()
+	if typeNamer == nil {
+		set.fs.PrintDefaults()
+		return
+	}
+
+	w := set.fs.Output()
+	set.fs.VisitAll(func(f *Flag) {
+		io.WriteString(w, flagDefaults(f, typeNamer))
+	})
+}
+
+func (set *Set) getTypeNamer() func(*Flag) string {
+	set.docMu.RLock()
+	defer set.docMu.RUnlock()
+	return set.typeNamer
+}
+
+// flagDefaults returns the PrintDefaults output for f alone, using typeNamer if not nil.
+func flagDefaults(f *Flag, typeNamer func(*Flag) string) string {
+	// print each flag on its own so that we retain the std/flag formatting
+	var buf strings.Builder
+	single := stdflag.NewFlagSet("", stdflag.ContinueOnError)
+	single.SetOutput(&buf)
+	single.Var(f.Value, f.Name, f.Usage)
+	single.Lookup(f.Name).DefValue = f.DefValue
+	single.PrintDefaults()
+	out := buf.String()
+
+	if typeNamer == nil {
+		return out
+	}
+	guess, usage := UnquoteUsage(f)
+	if usage != f.Usage {
+		// the name was given explicitly
+		return out
+	}
+	name := typeNamer(f)
+	if name == "" {
+		return out
+	}
+
+	// replace the guessed name in the first line, the separator depends on its length
+	header := func(name string) string {
+		h := "  -" + f.Name
+		if name != "" {
+			h += " " + name
+		}
+		if len(h) <= 4 {
+			return h + "\t"
+		}
+		return h + "\n    \t"
+	}
+	return header(name) + strings.TrimPrefix(out, header(guess))
+}
+
+// PrintDefaultsVerbose works like PrintDefaults, but it also prints the example
+// provided with SetExample, if any, under the usage of each flag.
+func (set *Set) PrintDefaultsVerbose() {
+	set.init()
+	typeNamer := set.getTypeNamer()
+	w := set.fs.Output()
+	set.fs.VisitAll(func(f *Flag) {
+		out := flagDefaults(f, typeNamer)
+		if example, ok := set.Annotation(f.Name, exampleAnnotation); ok {
+			out = fmt.Sprintf("%s\n    \t(example: %s)\n", strings.TrimSuffix(out, "\n"), example)
+		}
+		io.WriteString(w, out)
+	})
+}
 
 // PrintValues works like PrintDefaults, but it prints the current value for every
 // flag, annotated with the source of the value.
 //
 // It is meant as a debugging utility to troubleshoot value propagation when using
 // multiple sources for flag values (such as environment and config files).
-func (set *Set) PrintValues() {
-	w := set.Output()
+func (set *Set) PrintValues() { set.FprintValues(set.Output()) }
+
+// FprintValues works like PrintValues, but it prints to w instead of the Set's output.
+func (set *Set) FprintValues(w io.Writer) {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
 
 	name := set.fs.Name()
 	if name == "" {
@@ -202,23 +657,295 @@ func (set *Set) PrintValues() {
 		fmt.Fprintf(w, "Current configuration of %s:\n", name)
 	}
 
-	// quick and dirty way to align stuff
-	var prefixes, suffixes []string
-	var max int
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	set.fs.VisitAll(func(flag *Flag) {
+		fmt.Fprintf(tw, "  -%s %s\t(%s)\n", flag.Name, flag.Value.String(), set.provideMap[flag.Name])
+	})
+	tw.Flush()
+}
+
+// FlagProvenance records which source set a flag and the resulting value.
+type FlagProvenance struct {
+	Source Source
+	Value  string
+}
+
+// Provenance returns a snapshot of the source and current value (as given by
+// String) of every flag. It is a structured counterpart to PrintValues, useful
+// for asserting the precedence of config sources in tests.
+func (set *Set) Provenance() map[string]FlagProvenance {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	ret := make(map[string]FlagProvenance)
+	set.fs.VisitAll(func(flag *Flag) {
+		ret[flag.Name] = FlagProvenance{
+			Source: set.provideMap[flag.Name],
+			Value:  flag.Value.String(),
+		}
+	})
+	return ret
+}
+
+// Changed reports whether the named flag was set by any source, be it the
+// program arguments, an extra parser or a call to Set. It returns false for flags
+// at their default value and for unknown flags.
+//
+// It only reflects the state of the Set after Parse, before that every flag
+// is reported as unchanged unless it was set with Set.
+func (set *Set) Changed(name string) bool {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	src, ok := set.provideMap[name]
+	return ok && !isDefault(src)
+}
+
+// FlagsBySource groups the names of every flag by the source that set its value,
+// in lexicographical order. Flags with no recorded source, such as before Parse,
+// are omitted.
+//
+// Grouping is done by the exact Source, since parsers like env and file encode
+// details such as the variable name in it, every flag set by them ends up in a
+// group of its own. Use SourceInfo to group by kind instead.
+func (set *Set) FlagsBySource() map[Source][]string {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	ret := make(map[Source][]string)
+	set.fs.VisitAll(func(flag *Flag) {
+		if src, ok := set.provideMap[flag.Name]; ok {
+			ret[src] = append(ret[src], flag.Name)
+		}
+	})
+	return ret
+}
+
+// WriteINI writes the current configuration to w as "name = value" lines, followed
+// by a comment with the usage and source of each flag, suitable as a starting
+// point for a config file.
+//
+// Booleans and numbers are written as is, slices are written as arrays and every
+// other value (including types implementing [fmt.Stringer]) is written as a
+// quoted string. Names that are not valid bare keys, such as "http.addr", are
+// quoted too, so that the output is valid TOML.
+func (set *Set) WriteINI(w io.Writer) error {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	set.fs.VisitAll(func(flag *Flag) {
+		comment := "(" + string(set.provideMap[flag.Name]) + ")"
+		if usage := strings.Join(strings.Fields(flag.Usage), " "); usage != "" {
+			comment = usage + " " + comment
+		}
+		comment = strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f {
+				return -1 // not allowed in TOML comments
+			}
+			return r
+		}, comment)
+		fmt.Fprintf(tw, "%s\t= %s\t# %s\n", iniKey(flag.Name), iniValue(flag.Value), comment)
+	})
+	return tw.Flush()
+}
+
+// iniKey returns name as a TOML bare key if it is one, or quoted otherwise.
+func iniKey(name string) string {
+	if name == "" {
+		return `""`
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return iniQuote(name)
+		}
+	}
+	return name
+}
+
+// iniQuote returns s as a TOML basic string. Unlike strconv.Quote it only uses the
+// escapes allowed by TOML.
+func iniQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func iniValue(v stdflag.Value) string {
+	g, ok := v.(stdflag.Getter)
+	if !ok {
+		return iniQuote(v.String())
+	}
+	rv := reflect.ValueOf(g.Get())
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	return iniFormat(rv)
+}
+
+func iniFormat(rv reflect.Value) string {
+	if !rv.IsValid() {
+		return `""`
+	}
+	if s, ok := rv.Interface().(fmt.Stringer); ok {
+		return iniQuote(s.String())
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(rv.Interface())
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		}
+		ret := strconv.FormatFloat(f, 'g', -1, rv.Type().Bits())
+		if !strings.ContainsAny(ret, ".e") {
+			// keep it a float, TOML reads 2 as an integer
+			ret += ".0"
+		}
+		return ret
+	case reflect.Slice, reflect.Array:
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = iniFormat(rv.Index(i))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return `""`
+		}
+		return iniFormat(rv.Elem())
+	default:
+		return iniQuote(fmt.Sprint(rv.Interface()))
+	}
+}
+
+type jsonSchema struct {
+	Type     string      `json:"type"`
+	Items    *jsonSchema `json:"items,omitempty"`
+	Enum     []string    `json:"enum,omitempty"`
+	Default  any         `json:"default,omitempty"`
+	Usage    string      `json:"usage,omitempty"`
+	Required bool        `json:"required,omitempty"`
+}
+
+// JSONSchema writes a JSON object to w describing every flag, keyed by name, with
+// its type, default value, usage, whether it was marked with MarkRequired and, for
+// values implementing Enumerated, the allowed values.
+//
+// Types are inferred from the value returned by Get and are one of "boolean",
+// "integer", "number", "string", "array" or "object". Values implementing
+// [fmt.Stringer], such as durations, are described as strings. Defaults are
+// written as booleans and numbers for those types, and as strings otherwise.
+func (set *Set) JSONSchema(w io.Writer) error {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	flags := make(map[string]jsonSchema)
 	set.fs.VisitAll(func(flag *Flag) {
-		p := fmt.Sprintf("  -%s %s", flag.Name, flag.Value.String())
-		prefixes = append(prefixes, p)
-		if len(p) > max {
-			max = len(p)
+		var t reflect.Type
+		if g, ok := flag.Value.(stdflag.Getter); ok {
+			t = reflect.TypeOf(g.Get())
+		}
+		if t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		var schema jsonSchema
+		if e, ok := flag.Value.(Enumerated); ok {
+			items := jsonSchema{Type: "string", Enum: e.AllowedValues()}
+			schema = items
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Map) {
+				schema = jsonSchema{Type: "array", Items: &items}
+			}
+		} else {
+			schema = jsonSchemaOf(t)
 		}
 
-		s := fmt.Sprintf("(%s)\n", set.provideMap[flag.Name])
-		suffixes = append(suffixes, s)
-		// fmt.Fprintf(w, "  -%s %s (%s)\n", flag.Name, flag.Value.String(), set.provideMap[flag.Name])
+		schema.Default = jsonDefault(schema.Type, flag.DefValue)
+		_, schema.Usage = UnquoteUsage(flag)
+		_, schema.Required = set.Annotation(flag.Name, requiredAnnotation)
+		flags[flag.Name] = schema
 	})
 
-	for i, p := range prefixes {
-		fmt.Fprintf(w, "%s%s%s", p, strings.Repeat(" ", max-len(p)+1), suffixes[i])
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(flags)
+}
+
+// jsonDefault returns def as a boolean or a number if typ is one of those and def
+// is a valid value for it, or as is otherwise.
+func jsonDefault(typ, def string) any {
+	switch typ {
+	case "boolean":
+		if b, err := strconv.ParseBool(def); err == nil {
+			return b
+		}
+	case "integer":
+		if i, err := strconv.ParseInt(def, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(def, 10, 64); err == nil {
+			return u
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(def, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	}
+	return def
+}
+
+func jsonSchemaOf(t reflect.Type) jsonSchema {
+	if t == nil || t.Implements(reflect.TypeOf((*fmt.Stringer)(nil)).Elem()) {
+		return jsonSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		items := jsonSchemaOf(t.Elem())
+		return jsonSchema{Type: "array", Items: &items}
+	case reflect.Map, reflect.Struct:
+		return jsonSchema{Type: "object"}
+	default:
+		return jsonSchema{Type: "string"}
 	}
 }
 
@@ -240,18 +967,52 @@ func (set *Set) Args() []string { set.init(); return set.fs.Args() }
 // to populate values from different sources (such as environment values).
 type Parser func(*Set) error
 
+// Optional returns a Parser that runs p and, if it fails, writes the error to
+// the Set's output instead of failing, so that Parse carries on with the next
+// parsers. It is meant for best effort sources, such as a remote config store,
+// and it takes precedence over the Set's ErrorHandling.
+//
+// Flags set by p before failing keep their values.
+func Optional(p Parser) Parser {
+	return func(set *Set) error {
+		if err := p(set); err != nil {
+			fmt.Fprintf(set.Output(), "warning: optional parser failed: %v\n", err)
+		}
+		return nil
+	}
+}
+
+// MapParser returns a Parser that sets every flag that has not been set yet, and
+// whose name is a key in values, to the corresponding value, recording src as
+// its source. It is the simplest possible custom source, useful in tests and
+// for embedding configuration.
+func MapParser(values map[string]string, src Source) Parser {
+	return func(set *Set) error {
+		return set.VisitRemaining(func(f *Flag) error {
+			val, ok := values[f.Name]
+			if !ok {
+				return nil
+			}
+			return set.Set(src, f.Name, val)
+		})
+	}
+}
+
 // Parse parses flag definitions from the argument list, which should not
 // include the command name. Must be called after all flags in the Set
 // are defined and before flags are accessed by the program.
 // The return value will be ErrHelp if -help or -h were set but not defined.
 //
+// Flags that are not boolean always consume the next argument as their value,
+// so negative numbers can be given as "-n -5" as well as "-n=-5".
+//
 // After parsing the program arguments, it will call each extraParser, in the order
 // they were provided. If any parser fails Parse will return an error. Extra parsers
 // are only allowed to set flags that have not been set previously, either by
 // the program arguments or any other parser that was called before.
 //
 // This enables you to create a cascade of configuration sources with the precedence
-// you want.
+// you want. Once every parser has run, values implementing Validator are validated.
 //
 // In this example, we call Parse in such way that flags have priority over everything,
 // any flag that was not set explicitly may be set by the env parser, any flag
@@ -266,32 +1027,258 @@ type Parser func(*Set) error
 //	)
 func (set *Set) Parse(arguments []string, extraParsers ...Parser) error {
 	set.init()
-	if err := set.fs.Parse(arguments); err != nil {
+	if err := set.parseArgs(arguments); err != nil {
 		return err
 	}
 
-	// assume no args were passed in
-	set.fs.VisitAll(func(f *Flag) {
-		set.provideMap[f.Name] = SourceDefaultVal
-	})
+	for i, parser := range extraParsers {
+		if hook := set.getParseHook(); hook != nil {
+			var remaining int
+			set.VisitRemaining(func(*Flag) error {
+				remaining++
+				return nil
+			})
+			hook(i, parser, remaining)
+		}
+
+		if err := parser(set); err != nil {
+			return set.failParse(err)
+		}
+	}
+
+	if err := set.validate(); err != nil {
+		return set.failParse(err)
+	}
+
+	set.warnDefaults()
+	return nil
+}
+
+// ParseUntilArg behaves like Parse, parsing flags up to the first positional
+// argument, usually a subcommand, and reports how many arguments were consumed.
+// The positional argument and everything after it are left untouched in Args,
+// even if they look like flags, so that they can be parsed by the subcommand's
+// own Set:
+//
+//	// app -v serve -addr :80
+//	n, err := set.ParseUntilArg(os.Args[1:])
+//	// n == 1, set.Args() == [serve -addr :80]
+//
+// A "--" terminator is consumed and counted. If parsing fails consumed is 0.
+func (set *Set) ParseUntilArg(arguments []string, extraParsers ...Parser) (consumed int, err error) {
+	if err := set.Parse(arguments, extraParsers...); err != nil {
+		return 0, err
+	}
+	return len(arguments) - set.NArg(), nil
+}
+
+// failParse handles an error returned by an extra parser or a Validator
+// according to the Set's ErrorHandling.
+func (set *Set) failParse(err error) error {
+	switch set.fs.ErrorHandling() {
+	case ExitOnError:
+		os.Exit(2)
+	case PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// Validator is an optional interface that can be implemented by a Getter whose
+// value can only be validated as a whole, once every source had the chance to
+// set it, such as a list with a minimum number of elements.
+//
+// Parse calls Validate on every such value after all the extra parsers have run,
+// failing if it returns an error.
+type Validator interface {
+	Validate() error
+}
+
+func (set *Set) validate() error {
+	return set.VisitAll(func(f *Flag) error {
+		v, ok := f.Value.(Validator)
+		if !ok {
+			return nil
+		}
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %w", f.Value.String(), f.Name, err)
+		}
+		return nil
+	})
+}
+
+// WarnOnDefault makes Parse write a warning to Output for each of the named flags
+// that is still at its default value once all parsers have run. Parsing does not
+// fail because of it.
+//
+// This is a softer alternative to requiring a flag, meant for flags that have a
+// default but where relying on it is usually a mistake, such as a placeholder secret.
+// Names that do not match any flag are ignored.
+func (set *Set) WarnOnDefault(names ...string) {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.warnDefault = append(set.warnDefault, names...)
+}
+
+func (set *Set) warnDefaults() {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	for _, name := range set.warnDefault {
+		if src, ok := set.provideMap[name]; ok && isDefault(src) {
+			fmt.Fprintf(set.fs.Output(), "warning: flag -%s was not set, using its default value\n", name)
+		}
+	}
+}
+
+// ParseStrict behaves like Parse but, after all parsers have run, it also fails
+// with ErrUnused if any of them reported values that did not match any flag
+// trough ReportUnused. This is useful to catch typos and stale keys in config
+// sources.
+func (set *Set) ParseStrict(arguments []string, extraParsers ...Parser) error {
+	if err := set.Parse(arguments, extraParsers...); err != nil {
+		return err
+	}
+
+	set.mu.RLock()
+	unused := strings.Join(set.unused, ", ")
+	set.mu.RUnlock()
+	if unused == "" {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %s", ErrUnused, unused)
+	switch set.fs.ErrorHandling() {
+	case ExitOnError:
+		fmt.Fprintln(set.fs.Output(), err)
+		os.Exit(2)
+	case PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// ReportUnused is meant to be called by parsers to signal that the given names
+// (env vars, config keys, etc) did not match any flag.
+// It has no effect unless the Set is parsed with ParseStrict.
+func (set *Set) ReportUnused(names []string) {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.unused = append(set.unused, names...)
+}
+
+// Freeze makes the Set immutable, any subsequent attempt to add flags, parse or
+// set values trough the Set will fail with ErrFrozen. Add will panic, as it cannot
+// return an error, and so will every other method if the Set was created with PanicOnError.
+//
+// Read only methods like Lookup, Visit or PrintValues are not affected.
+// Note that the Set cannot prevent calling Set directly on a flag's Value.
+func (set *Set) Freeze() {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.frozen = true
+}
+
+func (set *Set) checkFrozen() error {
+	if !set.frozen {
+		return nil
+	}
+	if set.fs.ErrorHandling() == PanicOnError {
+		panic(ErrFrozen)
+	}
+	return ErrFrozen
+}
+
+// SetParseHook registers fn to be called by Parse before running each of the
+// extra parsers, with its index, the parser itself, and the number of flags that
+// have not been set yet. It is meant for logging and diagnostics.
+func (set *Set) SetParseHook(fn func(stage int, parser Parser, remaining int)) {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.parseHook = fn
+}
+
+func (set *Set) getParseHook() func(stage int, parser Parser, remaining int) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.parseHook
+}
+
+// parseArgs parses the program arguments and records the source of every flag.
+//
+// The lock is not held while the arguments are parsed: on -h or a bad flag the
+// std flag package calls Usage, which is free to use the Set.
+func (set *Set) parseArgs(arguments []string) error {
+	set.mu.Lock()
+	if err := set.checkFrozen(); err != nil {
+		set.mu.Unlock()
+		return err
+	}
+	set.unused = nil
+	set.mu.Unlock()
+
+	if err := set.fs.Parse(arguments); err != nil {
+		return err
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	// assume no args were passed in
+	set.fs.VisitAll(func(f *Flag) {
+		set.setDefaultSource(f.Name)
+	})
 	// overwrite any flag that has been set
 	set.fs.Visit(func(f *Flag) {
 		set.provideMap[f.Name] = SourceFlags
+		set.record(f.Name, SourceFlags)
 	})
+	return nil
+}
 
-	for _, parser := range extraParsers {
-		if err := parser(set); err != nil {
-			switch set.fs.ErrorHandling() {
-			case ContinueOnError:
-				return err
-			case ExitOnError:
-				os.Exit(2)
-			case PanicOnError:
-				panic(err)
-			}
-		}
+// TraceEntry is a single write to a flag, as recorded by EnableTrace.
+type TraceEntry struct {
+	Name   string
+	Value  string // The value of the flag after the write, as given by String.
+	Source Source
+	When   time.Time
+}
+
+// EnableTrace makes the Set record every successful write to a flag, through Set,
+// SetWithInfo, SetDefault and the program arguments given to Parse, including
+// the ones that are later overwritten. The trace is retrieved with Trace.
+//
+// It is meant for debugging surprising values in complex cascades of sources.
+// Program arguments are recorded once Parse is done with them, a single entry
+// per flag with its final value, in lexicographical order.
+func (set *Set) EnableTrace() {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.tracing = true
+}
+
+// Trace returns a copy of the writes recorded since EnableTrace was called, in order.
+func (set *Set) Trace() []TraceEntry {
+	set.init()
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return append([]TraceEntry(nil), set.trace...)
+}
+
+// record appends a write to the trace, if enabled. The caller must hold the lock.
+func (set *Set) record(name string, src Source) {
+	if !set.tracing {
+		return
 	}
-	return nil
+	set.trace = append(set.trace, TraceEntry{
+		Name:   name,
+		Value:  set.fs.Lookup(name).Value.String(),
+		Source: src,
+		When:   time.Now(),
+	})
 }
 
 // Parsed reports whether set.Parse has been called.
@@ -308,8 +1295,158 @@ func (set *Set) Init(name string, errorHandling ErrorHandling) {
 // Add creates a new flag on the given Set, returning the underlying value of the provided Getter.
 func Add[T any](set *Set, name string, value Getter[T], usage string) *T {
 	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.frozen {
+		panic(fmt.Errorf("%w: cannot add flag %s", ErrFrozen, name))
+	}
+	return add(set, name, value, usage)
+}
+
+// add defines the flag name for value and returns the underlying value of the
+// provided Getter. The caller must hold the lock.
+func add[T any](set *Set, name string, value Getter[T], usage string) *T {
+	inner := value
+	if e, ok := value.(envDefaultGetter[T]); ok {
+		inner = e.Getter
+	}
+	var snap *snapshot
+	if p := inner.Val(); p != nil {
+		snap = newSnapshot(p)
+	}
+	set.addVar(name, value, usage, snap)
+	return inner.Val()
+}
+
+// addVar defines the flag name for value, unwrapping EnvDefault getters, and
+// keeps snap so that Reset can restore its default. If snap is nil it is taken
+// from the pointer returned by Get, if any. The caller must hold the lock.
+func (set *Set) addVar(name string, value stdflag.Getter, usage string, snap *snapshot) {
+	var envName string
+	var found bool
+	if e, ok := value.(envDefaulted); ok {
+		value, envName, found = e.envDefault()
+	}
 	set.fs.Var(value, name, usage)
-	return value.Val()
+	if snap == nil {
+		snap = pointerSnapshot(value.Get())
+	}
+	if snap != nil {
+		set.snapshots[name] = snap
+	}
+	if found {
+		set.envDefaults[name] = envName
+		set.setDefaultSource(name)
+	}
+}
+
+// FlagDef describes a flag to be defined by AddAll.
+type FlagDef struct {
+	Name  string
+	Usage string
+	Value stdflag.Getter
+}
+
+// AddAll creates a flag on the Set for each of defs, in order. It is meant for
+// flags whose type is only known at runtime, such as those declared by plugins,
+// where calling Add is not possible. Values are accessed with Get or GetString,
+// or through the Getters in defs.
+//
+// Getters returned by EnvDefault are handled as by Add. Reset can only restore
+// the values of Getters whose Get method returns a pointer, as all the Getters
+// in this package do.
+//
+// If any name is already defined, either in the Set or in defs, it returns an
+// error wrapping [ErrRedefined] and no flag is created.
+func (set *Set) AddAll(defs []FlagDef) error {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if err := set.checkFrozen(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		if seen[def.Name] || set.fs.Lookup(def.Name) != nil {
+			return fmt.Errorf("%w: %s", ErrRedefined, def.Name)
+		}
+		seen[def.Name] = true
+	}
+	for _, def := range defs {
+		set.addVar(def.Name, def.Value, def.Usage, nil)
+	}
+	return nil
+}
+
+// setDefaultSource records the named flag as being at its default value.
+// The caller must hold the lock.
+func (set *Set) setDefaultSource(name string) {
+	if envName, ok := set.envDefaults[name]; ok {
+		set.provideMap[name] = SourceEnvDefault
+		set.infoMap[name] = SourceInfo{Kind: string(SourceEnvDefault), Detail: envName}
+		return
+	}
+	set.provideMap[name] = SourceDefaultVal
+	delete(set.infoMap, name)
+}
+
+// envDefaulted is implemented by the Getters returned by EnvDefault.
+type envDefaulted interface {
+	envDefault() (value stdflag.Getter, envName string, found bool)
+}
+
+type envDefaultGetter[T any] struct {
+	Getter[T]
+	envName string
+	found   bool
+}
+
+func (e envDefaultGetter[T]) envDefault() (stdflag.Getter, string, bool) {
+	return e.Getter, e.envName, e.found
+}
+
+// EnvDefault returns value with its default replaced by the contents of the env
+// var envName, read when EnvDefault is called, or by fallback if it is not set.
+// If fallback is empty the default of value is kept. Either way the string is
+// parsed by value, so it is shown by the usage message like any other default.
+// It panics if the string cannot be parsed.
+//
+//	port := flagr.Add(set, "port", flagr.EnvDefault("PORT", "8080", flagr.Int(0)), "")
+//
+// Unlike env.Parse, which fills flags that were not set once Parse is called, the
+// env var becomes the default itself: any source, including extra parsers, can
+// still override it. While the flag keeps that value, its source is SourceEnvDefault
+// and its SourceInfo detail is envName, Changed reports false for it.
+func EnvDefault[T any](envName, fallback string, value Getter[T]) Getter[T] {
+	def, found := os.LookupEnv(envName)
+	if !found {
+		def = fallback
+	}
+	if def != "" || found {
+		if err := value.Set(def); err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", def, err))
+		}
+		if d, ok := value.(defaulter); ok {
+			d.markDefault()
+		}
+	}
+	return envDefaultGetter[T]{Getter: value, envName: envName, found: found}
+}
+
+// TryAdd, like Add, creates a new flag on the given Set, returning the underlying value of the provided Getter.
+// Unlike Add, if a flag with the same name already exists it returns an error wrapping [ErrRedefined] instead of panicking.
+func TryAdd[T any](set *Set, name string, value Getter[T], usage string) (*T, error) {
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.fs.Lookup(name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRedefined, name)
+	}
+	if err := set.checkFrozen(); err != nil {
+		return nil, err
+	}
+	return add(set, name, value, usage), nil
 }
 
 // Int returns a Getter that can parse values of type int.
@@ -578,6 +1715,30 @@ func MustIPAddrPorts(defaults ...string) Getter[[]netip.AddrPort] {
 	return MustSlice(defaults, netip.ParseAddrPort)
 }
 
+// MAC returns a Getter that can parse values of type net.HardwareAddr.
+func MAC(defaultValue net.HardwareAddr) Getter[net.HardwareAddr] {
+	return Var(defaultValue, set(net.ParseMAC))
+}
+
+// MustMAC, like MAC, returns a Getter that can parse values of type net.HardwareAddr, but
+// allowing the default value to be provided as a string. It panics if the given string cannot be parsed
+// as net.HardwareAddr.
+func MustMAC(defaultValue string) Getter[net.HardwareAddr] {
+	return MustVar(defaultValue, set(net.ParseMAC))
+}
+
+// MACs returns a Getter that can parse and accumulate values of type net.HardwareAddr.
+func MACs(defaults ...net.HardwareAddr) Getter[[]net.HardwareAddr] {
+	return Slice(defaults, net.ParseMAC)
+}
+
+// MustMACs, like MACs, returns a Getter that can parse values of type net.HardwareAddr and accumulate them, but
+// allowing the default values to be provided as strings. It panics if any given string cannot be parsed
+// as net.HardwareAddr.
+func MustMACs(defaults ...string) Getter[[]net.HardwareAddr] {
+	return MustSlice(defaults, net.ParseMAC)
+}
+
 // Getter is any type that satisfies flag.Getter and provides a new method Val()
 // that returns a pointer to the actual value of type.
 //
@@ -610,6 +1771,26 @@ type Getter[T any] interface {
 	Val() *T
 }
 
+// Repeatable is an optional interface that can be implemented by a Getter to
+// signal that its value accumulates when the flag is provided multiple times.
+// Getters that don't implement it are not considered repeatable.
+type Repeatable interface {
+	IsSlice() bool
+}
+
+// IsRepeatable reports whether the given flag accumulates values when provided
+// multiple times, that is, whether its value implements [Repeatable] and IsSlice
+// returns true.
+func IsRepeatable(f *Flag) bool {
+	r, ok := f.Value.(Repeatable)
+	return ok && r.IsSlice()
+}
+
+// Enumerated is implemented by values that only accept a fixed set of values.
+type Enumerated interface {
+	AllowedValues() []string
+}
+
 // ValParser is a func that parses a string into T.
 type ValParser[T any] func(string) (T, error)
 
@@ -761,7 +1942,7 @@ func (f *slice[T, S]) Set(s string) error {
 }
 
 func (s *slice[T, S]) String() string {
-	if s.Value == nil {
+	if s == nil || s.Value == nil {
 		return "<nil>"
 	}
 
@@ -781,6 +1962,15 @@ func (s *slice[T, S]) IsBoolFlag() bool {
 	return reflect.TypeOf(S{}).Elem().Kind() == reflect.Bool
 }
 
+func (s *slice[T, S]) markDefault() {
+	s.written = false
+}
+
+// IsSlice reports that the flag accumulates values when provided multiple times.
+func (s *slice[T, S]) IsSlice() bool {
+	return true
+}
+
 func parseInt[T ~int8 | ~int16 | ~int32 | ~int64 | ~int](s string) (T, error) {
 	var zero T
 	v, err := strconv.ParseInt(s, 0, int(unsafe.Sizeof(zero)*8))
@@ -822,3 +2012,3149 @@ func parseString(s string) (string, error) { return s, nil }
 func parseTime(layout string) func(string) (time.Time, error) {
 	return func(s string) (time.Time, error) { return time.Parse(layout, s) }
 }
+
+// Level returns a Getter that can parse named, ordered levels into their index.
+//
+// names[i] is the name for level i, so the order of names defines the order of
+// the levels. When set, the given value is matched case-insensitively against
+// names and the index of the match is stored. Because the value is an int,
+// levels can be compared directly:
+//
+//	lvl := flagr.Add(&set, "level", flagr.Level(1, []string{"debug", "info", "warn", "error"}), "log level")
+//	if *lvl <= 0 {
+//		// debug logging is enabled
+//	}
+//
+// It panics if defaultValue is not a valid index into names.
+func Level(defaultValue int, names []string) Getter[int] {
+	if defaultValue < 0 || defaultValue >= len(names) {
+		panic(fmt.Errorf("flag: invalid default value %d: must be in range [0, %d)", defaultValue, len(names)))
+	}
+	return level{
+		Value: &defaultValue,
+		Names: names,
+	}
+}
+
+var _ Getter[int] = level{}
+
+type level struct {
+	Value *int
+	Names []string
+}
+
+func (l level) Get() any {
+	return l.Value
+}
+
+func (l level) Val() *int {
+	return l.Value
+}
+
+func (l level) Set(s string) error {
+	for i, name := range l.Names {
+		if strings.EqualFold(name, s) {
+			*l.Value = i
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid level %q, must be one of: %s", s, strings.Join(l.Names, ", "))
+}
+
+func (l level) String() string {
+	if l.Value == nil {
+		return "<nil>"
+	}
+	if *l.Value < 0 || *l.Value >= len(l.Names) {
+		return strconv.Itoa(*l.Value)
+	}
+	return l.Names[*l.Value]
+}
+
+func (l level) AllowedValues() []string {
+	return l.Names
+}
+
+func (l level) IsBoolFlag() bool {
+	return false
+}
+
+var _ Getter[[]any] = &multiSlice[any, []any]{}
+
+// multiSlice is a slice where a single call to Set may produce multiple values.
+type multiSlice[T any, S ~[]T] struct {
+	*slice[T, S]
+	ParseAll ValParser[S]
+}
+
+func newMultiSlice[T any, S ~[]T](defaultValue S, parse ValParser[S]) *multiSlice[T, S] {
+	return &multiSlice[T, S]{
+		slice:    Slice(defaultValue, ValParser[T](nil)),
+		ParseAll: parse,
+	}
+}
+
+// isNil reports whether m holds no value, as is the case for the zero values
+// the std flag package builds to find out if a default should be printed.
+func (m *multiSlice[T, S]) isNil() bool {
+	return m == nil || m.slice == nil || m.Value == nil
+}
+
+func (m *multiSlice[T, S]) String() string {
+	if m.isNil() {
+		return "<nil>"
+	}
+	return m.slice.String()
+}
+
+func (m *multiSlice[T, S]) Set(s string) error {
+	if !m.written {
+		*m.Value = (*m.Value)[:0]
+		m.written = true
+	}
+	v, err := m.ParseAll(s)
+	if err != nil {
+		return err
+	}
+	*m.Value = append(*m.Value, v...)
+	return nil
+}
+
+// IntRanges returns a Getter that can parse and accumulate comma separated lists
+// of ints and inclusive int ranges, expanding the ranges.
+//
+// Given "0-2,4,6-7" the resulting value is [0 1 2 4 6 7]. A single range may
+// not expand to more than 65536 values.
+func IntRanges(defaults ...int) Getter[[]int] {
+	return newMultiSlice(defaults, parseIntRanges)
+}
+
+// maxIntRange is the maximum number of values a single range given to IntRanges
+// can expand to.
+const maxIntRange = 1 << 16
+
+func parseIntRanges(s string) ([]int, error) {
+	var ret []int
+	for _, tok := range strings.Split(s, ",") {
+		tok = strings.TrimSpace(tok)
+
+		// skip the first char so that negative numbers are not treated as ranges
+		sep := -1
+		if len(tok) > 0 {
+			if i := strings.Index(tok[1:], "-"); i >= 0 {
+				sep = i + 1
+			}
+		}
+
+		if sep < 0 {
+			v, err := parseInt[int](tok)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %w", tok, err)
+			}
+			ret = append(ret, v)
+			continue
+		}
+
+		lo, err := parseInt[int](tok[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", tok, err)
+		}
+		hi, err := parseInt[int](tok[sep+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", tok, err)
+		}
+		if hi < lo {
+			return nil, fmt.Errorf("invalid range %q: %d is greater than %d", tok, lo, hi)
+		}
+		if uint64(hi)-uint64(lo) >= maxIntRange {
+			return nil, fmt.Errorf("invalid range %q: expands to more than %d values", tok, maxIntRange)
+		}
+		for i := lo; ; i++ {
+			ret = append(ret, i)
+			if i == hi {
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+// IOTarget is the value of an IOArg flag. Path is the value as provided and IsStd
+// reports whether it was "-", which conventionally means stdin or stdout.
+type IOTarget struct {
+	Path  string
+	IsStd bool
+}
+
+func (t IOTarget) String() string {
+	return t.Path
+}
+
+// IOArg returns a Getter that can parse paths to files, where "-" means stdin or stdout.
+func IOArg(defaultValue string) Getter[IOTarget] {
+	v, _ := parseIOTarget(defaultValue)
+	return Var(v, set(parseIOTarget))
+}
+
+func parseIOTarget(s string) (IOTarget, error) {
+	return IOTarget{Path: s, IsStd: s == "-"}, nil
+}
+
+// Toggle is the value of a BoolOrDuration flag. A zero TTL means that there's no expiry.
+type Toggle struct {
+	Enabled bool
+	TTL     time.Duration
+}
+
+func (t Toggle) String() string {
+	switch {
+	case !t.Enabled:
+		return "false"
+	case t.TTL == 0:
+		return "true"
+	default:
+		return t.TTL.String()
+	}
+}
+
+// BoolOrDuration returns a Getter that can parse values that are either a bool or a time.Duration,
+// which is useful for things that can be toggled for a limited amount of time.
+//
+// std/flag bool semantics apply, so a bare "-flag" is the same as "-flag=true".
+//
+// Values are parsed as a bool first, with the same rules as Bool, which enables or
+// disables the flag without a TTL. If that fails, the value is parsed as a time.Duration,
+// enabling the flag with the given TTL. Negative durations are not allowed.
+func BoolOrDuration(defaultValue Toggle) Getter[Toggle] {
+	return boolOrDuration{Value: &defaultValue}
+}
+
+var _ Getter[Toggle] = boolOrDuration{}
+
+type boolOrDuration struct {
+	Value *Toggle
+}
+
+func (b boolOrDuration) Get() any {
+	return b.Value
+}
+
+func (b boolOrDuration) Val() *Toggle {
+	return b.Value
+}
+
+func (b boolOrDuration) Set(s string) error {
+	if v, err := strconv.ParseBool(s); err == nil {
+		*b.Value = Toggle{Enabled: v}
+		return nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid value %q, must be a bool or a duration", s)
+	}
+	if d < 0 {
+		return fmt.Errorf("invalid value %q, duration cannot be negative", s)
+	}
+	*b.Value = Toggle{Enabled: true, TTL: d}
+	return nil
+}
+
+func (b boolOrDuration) String() string {
+	if b.Value == nil {
+		return "<nil>"
+	}
+	return b.Value.String()
+}
+
+func (b boolOrDuration) IsBoolFlag() bool {
+	return true
+}
+
+// FeatureSet returns a Getter that can parse comma separated lists of features
+// into a map of known features to whether they're enabled.
+//
+// Every known feature starts disabled. Each feature in the list is enabled, unless
+// it is prefixed with a "-", in which case it is disabled. A "+" prefix is also
+// accepted and is the same as no prefix. Repeated flags are merged, in order.
+//
+// Given "a,b,-c" the features a and b are enabled and c is disabled.
+func FeatureSet(known []string) Getter[map[string]bool] {
+	features := make(map[string]bool, len(known))
+	for _, name := range known {
+		features[name] = false
+	}
+	return featureSet{Value: &features}
+}
+
+var _ Getter[map[string]bool] = featureSet{}
+
+type featureSet struct {
+	Value *map[string]bool
+}
+
+func (f featureSet) Get() any {
+	return f.Value
+}
+
+func (f featureSet) Val() *map[string]bool {
+	return f.Value
+}
+
+func (f featureSet) Set(s string) error {
+	// validate every token before applying any of them
+	changes := make(map[string]bool)
+	for _, tok := range strings.Split(s, ",") {
+		tok = strings.TrimSpace(tok)
+		enabled := true
+		switch {
+		case strings.HasPrefix(tok, "-"):
+			tok, enabled = tok[1:], false
+		case strings.HasPrefix(tok, "+"):
+			tok = tok[1:]
+		}
+
+		if _, ok := (*f.Value)[tok]; !ok {
+			return fmt.Errorf("unknown feature %q, must be one of: %s", tok, strings.Join(sortedKeys(*f.Value), ", "))
+		}
+		changes[tok] = enabled
+	}
+	for name, enabled := range changes {
+		(*f.Value)[name] = enabled
+	}
+	return nil
+}
+
+func (f featureSet) AllowedValues() []string {
+	if f.Value == nil {
+		return nil
+	}
+	return sortedKeys(*f.Value)
+}
+
+func (f featureSet) String() string {
+	if f.Value == nil {
+		return "<nil>"
+	}
+
+	var enabled []string
+	for _, name := range sortedKeys(*f.Value) {
+		if (*f.Value)[name] {
+			enabled = append(enabled, name)
+		}
+	}
+	return strings.Join(enabled, ",")
+}
+
+func (f featureSet) IsBoolFlag() bool {
+	return false
+}
+
+// Toggles returns a Getter that can parse comma separated lists of "name=state"
+// pairs, where state is one of on, off, true or false, ignoring case. Values are
+// merged into the defaults and across repeated flags, the last state given for
+// a name wins.
+//
+// Unlike FeatureSet, any name is accepted. The value is printed as sorted
+// "name=on" or "name=off" pairs.
+func Toggles(defaults map[string]bool) Getter[map[string]bool] {
+	toggles := make(map[string]bool, len(defaults))
+	for name, on := range defaults {
+		toggles[name] = on
+	}
+	return togglesValue{Value: &toggles}
+}
+
+var _ Getter[map[string]bool] = togglesValue{}
+
+type togglesValue struct {
+	Value *map[string]bool
+}
+
+func (t togglesValue) Get() any {
+	return t.Value
+}
+
+func (t togglesValue) Val() *map[string]bool {
+	return t.Value
+}
+
+func (t togglesValue) Set(s string) error {
+	parsed := make(map[string]bool)
+	for _, tok := range strings.Split(s, ",") {
+		name, state, ok := strings.Cut(strings.TrimSpace(tok), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid toggle %q, must be in the form name=on|off", tok)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(state)) {
+		case "on", "true":
+			parsed[name] = true
+		case "off", "false":
+			parsed[name] = false
+		default:
+			return fmt.Errorf("invalid state %q for %q, must be one of: on, off, true, false", state, name)
+		}
+	}
+
+	for name, on := range parsed {
+		(*t.Value)[name] = on
+	}
+	return nil
+}
+
+func (t togglesValue) String() string {
+	if t.Value == nil {
+		return "<nil>"
+	}
+
+	var pairs []string
+	for _, name := range sortedKeys(*t.Value) {
+		state := "off"
+		if (*t.Value)[name] {
+			state = "on"
+		}
+		pairs = append(pairs, name+"="+state)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (t togglesValue) IsBoolFlag() bool {
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ISODuration returns a Getter that can parse ISO-8601 durations, such as "PT1H30M" or "P1DT12H", into a time.Duration.
+//
+// Days are assumed to be 24h long and weeks 7 days long. Years and months are
+// rejected as their length is ambiguous. Fractions are allowed in every component.
+//
+// The value is printed using time.Duration's format.
+func ISODuration(defaultValue time.Duration) Getter[time.Duration] {
+	return Var(defaultValue, set(parseISODuration))
+}
+
+// ISODurations returns a Getter that can parse and accumulate ISO-8601 durations.
+// See ISODuration for the supported syntax.
+func ISODurations(defaults ...time.Duration) Getter[[]time.Duration] {
+	return Slice(defaults, parseISODuration)
+}
+
+func parseISODuration(s string) (time.Duration, error) {
+	orig := s
+
+	var neg bool
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") || len(s) == 1 {
+		return 0, fmt.Errorf("invalid ISO-8601 duration %q", orig)
+	}
+	s = s[1:]
+
+	// units in the order they must appear
+	units := []struct {
+		designator string
+		time       bool
+		duration   time.Duration
+	}{
+		{"W", false, 7 * 24 * time.Hour},
+		{"D", false, 24 * time.Hour},
+		{"H", true, time.Hour},
+		{"M", true, time.Minute},
+		{"S", true, time.Second},
+	}
+
+	var total float64
+	var inTime bool
+	next := 0
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("invalid ISO-8601 duration %q", orig)
+			}
+			inTime, s = true, s[1:]
+			continue
+		}
+
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("invalid ISO-8601 duration %q", orig)
+		}
+		num, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO-8601 duration %q: %w", orig, err)
+		}
+		designator := s[i : i+1]
+		s = s[i+1:]
+
+		if !inTime && (designator == "Y" || designator == "M") {
+			return 0, fmt.Errorf("invalid ISO-8601 duration %q: years and months are not supported", orig)
+		}
+
+		found := false
+		for next < len(units) {
+			u := units[next]
+			next++
+			if u.designator == designator && u.time == inTime {
+				total += num * float64(u.duration)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid ISO-8601 duration %q: unexpected %q", orig, designator)
+		}
+	}
+
+	// float64(math.MaxInt64) rounds up to 1<<63, which is already out of range
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid ISO-8601 duration %q: out of range", orig)
+	}
+	d := time.Duration(total)
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// Lines returns a Getter that can parse and accumulate newline separated strings.
+//
+// Both "\n" and "\r\n" are accepted as separators and trailing empty lines are
+// discarded. This is useful for values that come from the environment, where
+// lists are commonly newline separated.
+func Lines(defaults ...string) Getter[[]string] {
+	return lines{newMultiSlice(defaults, parseLines)}
+}
+
+type lines struct {
+	*multiSlice[string, []string]
+}
+
+func (l lines) String() string {
+	if l.isNil() {
+		return "<nil>"
+	}
+
+	var buf strings.Builder
+	buf.WriteByte('[')
+	for i, v := range *l.Value {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(v))
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+func parseLines(s string) ([]string, error) {
+	ret := strings.Split(s, "\n")
+	for i, line := range ret {
+		ret[i] = strings.TrimSuffix(line, "\r")
+	}
+	for len(ret) > 0 && ret[len(ret)-1] == "" {
+		ret = ret[:len(ret)-1]
+	}
+	return ret, nil
+}
+
+// PrefixSetOption configures a PrefixSet.
+type PrefixSetOption func(*prefixSetOptions)
+
+type prefixSetOptions struct {
+	disallowOverlap bool
+}
+
+// DisallowOverlap makes a PrefixSet fail if a prefix overlaps any other prefix previously provided.
+func DisallowOverlap() PrefixSetOption {
+	return func(o *prefixSetOptions) {
+		o.disallowOverlap = true
+	}
+}
+
+// PrefixSet returns a Getter that can parse and accumulate values of type netip.Prefix.
+//
+// If DisallowOverlap is given, providing a prefix that overlaps with another
+// one that has already been provided is an error. Defaults are not considered.
+func PrefixSet(defaults []netip.Prefix, opts ...PrefixSetOption) Getter[[]netip.Prefix] {
+	var o prefixSetOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := Slice(defaults, netip.ParsePrefix)
+	if o.disallowOverlap {
+		s.Parse = func(str string) (netip.Prefix, error) {
+			v, err := netip.ParsePrefix(str)
+			if err != nil {
+				return v, err
+			}
+			for _, existing := range *s.Value {
+				if existing.Overlaps(v) {
+					return v, fmt.Errorf("prefix %s overlaps %s", v, existing)
+				}
+			}
+			return v, nil
+		}
+	}
+	return s
+}
+
+// IPOrPrefix returns a Getter that can parse and accumulate values that are
+// either a prefix in CIDR notation or a bare IP address, normalizing them to
+// netip.Prefix. Bare addresses become a single host prefix, /32 for IPv4 and
+// /128 for IPv6, so that "10.0.0.5" is equivalent to "10.0.0.5/32".
+// It panics if any given default cannot be parsed.
+func IPOrPrefix(defaults ...string) Getter[[]netip.Prefix] {
+	return MustSlice(defaults, parseIPOrPrefix)
+}
+
+func parseIPOrPrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p, nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP or prefix %q", s)
+	}
+	return addr.Prefix(addr.BitLen())
+}
+
+// CIDRs is a list of prefixes, usable as an allowlist.
+type CIDRs []netip.Prefix
+
+// Contains reports whether addr is contained in any of the prefixes. IPv4-mapped
+// IPv6 addresses are matched against IPv4 prefixes.
+func (c CIDRs) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range c {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CIDRSet returns a Getter that can parse and accumulate lists of prefixes in
+// CIDR notation separated by sep, such as "10.0.0.0/8,fd00::/8".
+func CIDRSet(sep string, defaults ...netip.Prefix) Getter[CIDRs] {
+	return newMultiSlice(CIDRs(defaults), func(s string) (CIDRs, error) {
+		var ret CIDRs
+		for _, tok := range strings.Split(s, sep) {
+			tok = strings.TrimSpace(tok)
+			p, err := netip.ParsePrefix(tok)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prefix %q", tok)
+			}
+			ret = append(ret, p)
+		}
+		return ret, nil
+	})
+}
+
+// KeyValue is a single key=value pair.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+func (kv KeyValue) String() string {
+	return kv.Key + "=" + kv.Value
+}
+
+// KeyValues returns a Getter that can parse and accumulate key=value pairs,
+// preserving their order and any duplicate keys.
+//
+// Values are split on the first "=", if there is none the whole value is used
+// as the key and the value is empty.
+// It panics if any given default cannot be parsed.
+func KeyValues(defaults ...string) Getter[[]KeyValue] {
+	return MustSlice(defaults, parseKeyValue)
+}
+
+// StrictKeyValues, like KeyValues, returns a Getter that can parse and accumulate
+// key=value pairs, but values without a "=" are rejected.
+// It panics if any given default cannot be parsed.
+func StrictKeyValues(defaults ...string) Getter[[]KeyValue] {
+	return MustSlice(defaults, parseStrictKeyValue)
+}
+
+func parseKeyValue(s string) (KeyValue, error) {
+	k, v, _ := strings.Cut(s, "=")
+	return KeyValue{Key: k, Value: v}, nil
+}
+
+func parseStrictKeyValue(s string) (KeyValue, error) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return KeyValue{}, fmt.Errorf("invalid key value pair %q, missing \"=\"", s)
+	}
+	return KeyValue{Key: k, Value: v}, nil
+}
+
+// StringOption validates a string value, returning an error if it is not valid.
+type StringOption func(string) error
+
+// NonEmpty rejects empty strings.
+func NonEmpty() StringOption {
+	return func(s string) error {
+		if s == "" {
+			return errors.New("invalid value, must not be empty")
+		}
+		return nil
+	}
+}
+
+// MinLen rejects strings shorter than n characters.
+func MinLen(n int) StringOption {
+	return func(s string) error {
+		if utf8.RuneCountInString(s) < n {
+			return fmt.Errorf("invalid value %q, must be at least %d characters long", s, n)
+		}
+		return nil
+	}
+}
+
+// MaxLen rejects strings longer than n characters.
+func MaxLen(n int) StringOption {
+	return func(s string) error {
+		if utf8.RuneCountInString(s) > n {
+			return fmt.Errorf("invalid value %q, must be at most %d characters long", s, n)
+		}
+		return nil
+	}
+}
+
+// Match rejects strings that do not match re.
+func Match(re *regexp.Regexp) StringOption {
+	return func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("invalid value %q, must match %s", s, re)
+		}
+		return nil
+	}
+}
+
+// StringValidated returns a Getter for a string that must pass every given option.
+// The default value is not validated.
+func StringValidated(defaultValue string, opts ...StringOption) Getter[string] {
+	return Var(defaultValue, set(parseValidatedString(opts)))
+}
+
+// StringsValidated returns a Getter that can parse and accumulate strings, each
+// of which must pass every given option.
+// The default values are not validated.
+func StringsValidated(defaults []string, opts ...StringOption) Getter[[]string] {
+	return Slice(defaults, parseValidatedString(opts))
+}
+
+func parseValidatedString(opts []StringOption) ValParser[string] {
+	return func(s string) (string, error) {
+		for _, opt := range opts {
+			if err := opt(s); err != nil {
+				return "", err
+			}
+		}
+		return s, nil
+	}
+}
+
+var _ Getter[any] = mapValue[any, any]{}
+
+type mapValue[A, B any] struct {
+	Value     *B
+	Inner     Getter[A]
+	Transform func(A) (B, error)
+}
+
+// MapValue returns a Getter[B] that parses values using inner and then converts
+// the result using transform. Errors from either stage are returned by Set.
+//
+// Repeatable inner getters are supported, in which case transform is given
+// all the accumulated values every time.
+//
+// It panics if transform fails to convert the default value of inner.
+func MapValue[A, B any](inner Getter[A], transform func(A) (B, error)) Getter[B] {
+	v, err := transform(*inner.Val())
+	if err != nil {
+		panic(fmt.Errorf("flag: invalid default value %q: %w", inner.String(), err))
+	}
+	return mapValue[A, B]{
+		Value:     &v,
+		Inner:     inner,
+		Transform: transform,
+	}
+}
+
+func (m mapValue[A, B]) Get() any {
+	return m.Value
+}
+
+func (m mapValue[A, B]) Val() *B {
+	return m.Value
+}
+
+func (m mapValue[A, B]) Set(s string) error {
+	if err := m.Inner.Set(s); err != nil {
+		return err
+	}
+	v, err := m.Transform(*m.Inner.Val())
+	if err != nil {
+		return err
+	}
+	*m.Value = v
+	return nil
+}
+
+func (m mapValue[A, B]) String() string {
+	if m.Value == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(*m.Value)
+}
+
+func (m mapValue[A, B]) IsBoolFlag() bool {
+	return m.Inner.IsBoolFlag()
+}
+
+func (m mapValue[A, B]) IsSlice() bool {
+	r, ok := m.Inner.(Repeatable)
+	return ok && r.IsSlice()
+}
+
+func (m mapValue[A, B]) markDefault() {
+	if d, ok := m.Inner.(defaulter); ok {
+		d.markDefault()
+	}
+}
+
+// Version is a semantic version, as defined by https://semver.org.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string // Dot separated pre-release identifiers, without the leading "-".
+	Build               string // Dot separated build metadata, without the leading "+".
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is lower, equal or greater
+// than other, according to semver precedence rules. Build metadata is ignored.
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.Pre == other.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case other.Pre == "":
+		return -1
+	}
+
+	a, b := strings.Split(v.Pre, "."), strings.Split(other.Pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		aNum, bNum := isSemVerNum(a[i]), isSemVerNum(b[i])
+		switch {
+		case aNum && bNum:
+			// no leading zeroes, so a longer number is always greater
+			if c := compareInt(len(a[i]), len(b[i])); c != 0 {
+				return c
+			}
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+func isSemVerNum(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SemVer returns a Getter that can parse semantic versions such as "1.2.3-rc.1+build.5".
+// It panics if defaultValue cannot be parsed.
+func SemVer(defaultValue string) Getter[Version] {
+	return MustVar(defaultValue, set(parseSemVer))
+}
+
+// SemVers returns a Getter that can parse and accumulate semantic versions.
+// It panics if any given default cannot be parsed.
+func SemVers(defaults ...string) Getter[[]Version] {
+	return MustSlice(defaults, parseSemVer)
+}
+
+func parseSemVer(s string) (Version, error) {
+	rest, build, hasBuild := strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(rest, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q, must be in the form MAJOR.MINOR.PATCH", s)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := parseSemVerNum(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		nums[i] = n
+	}
+
+	if hasPre {
+		for _, id := range strings.Split(pre, ".") {
+			if err := validSemVerIdent(id); err != nil {
+				return Version{}, fmt.Errorf("invalid version %q, bad pre-release: %w", s, err)
+			}
+			if isSemVerNum(id) {
+				if _, err := parseSemVerNum(id); err != nil {
+					return Version{}, fmt.Errorf("invalid version %q, bad pre-release: %w", s, err)
+				}
+			}
+		}
+	}
+
+	if hasBuild {
+		for _, id := range strings.Split(build, ".") {
+			if err := validSemVerIdent(id); err != nil {
+				return Version{}, fmt.Errorf("invalid version %q, bad build metadata: %w", s, err)
+			}
+		}
+	}
+
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2], Pre: pre, Build: build}, nil
+}
+
+func parseSemVerNum(s string) (int, error) {
+	if !isSemVerNum(s) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has leading zeroes", s)
+	}
+	return strconv.Atoi(s)
+}
+
+func validSemVerIdent(s string) error {
+	if s == "" {
+		return errors.New("empty identifier")
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+			return fmt.Errorf("identifier %q contains invalid characters", s)
+		}
+	}
+	return nil
+}
+
+// Clock is a time of day, without a date or location.
+type Clock struct {
+	Hour, Minute, Second int
+}
+
+// String returns the clock in the "15:04" form, or "15:04:05" if it has seconds.
+func (c Clock) String() string {
+	if c.Second != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", c.Hour, c.Minute, c.Second)
+	}
+	return fmt.Sprintf("%02d:%02d", c.Hour, c.Minute)
+}
+
+// TimeOfDay returns a Getter that can parse times of day in the "15:04" or "15:04:05" forms.
+// It panics if defaultValue cannot be parsed.
+func TimeOfDay(defaultValue string) Getter[Clock] {
+	return MustVar(defaultValue, set(parseClock))
+}
+
+// TimesOfDay returns a Getter that can parse and accumulate times of day in the
+// "15:04" or "15:04:05" forms.
+// It panics if any given default cannot be parsed.
+func TimesOfDay(defaults ...string) Getter[[]Clock] {
+	return MustSlice(defaults, parseClock)
+}
+
+func parseClock(s string) (Clock, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Clock{}, fmt.Errorf("invalid time of day %q, must be in the form HH:MM or HH:MM:SS", s)
+	}
+
+	limits := []int{23, 59, 59}
+	var nums [3]int
+	for i, part := range parts {
+		if len(part) != 2 && !(i == 0 && len(part) == 1) || strings.Trim(part, "0123456789") != "" {
+			return Clock{}, fmt.Errorf("invalid time of day %q, must be in the form HH:MM or HH:MM:SS", s)
+		}
+		n, _ := strconv.Atoi(part)
+		if n > limits[i] {
+			return Clock{}, fmt.Errorf("invalid time of day %q, %s out of range", s, [...]string{"hour", "minute", "second"}[i])
+		}
+		nums[i] = n
+	}
+
+	return Clock{Hour: nums[0], Minute: nums[1], Second: nums[2]}, nil
+}
+
+// EnumSet returns a Getter that can parse and accumulate lists of values separated
+// by sep, where each element must be one of allowed. Duplicates are kept, see
+// EnumSetDedupe.
+// It panics if any given default is not allowed.
+func EnumSet(sep string, allowed []string, defaults ...string) Getter[[]string] {
+	return newEnumSet(sep, allowed, false, false, defaults)
+}
+
+// EnumSetFold is like EnumSet but elements are matched against allowed ignoring
+// case. The resulting values are always spelled as in allowed.
+func EnumSetFold(sep string, allowed []string, defaults ...string) Getter[[]string] {
+	return newEnumSet(sep, allowed, true, false, defaults)
+}
+
+// EnumSetDedupe is like EnumSet but duplicates are removed, keeping the first
+// occurrence.
+func EnumSetDedupe(sep string, allowed []string, defaults ...string) Getter[[]string] {
+	return newEnumSet(sep, allowed, false, true, defaults)
+}
+
+// EnumSetFoldDedupe is like EnumSetFold but duplicates are removed, keeping the
+// first occurrence.
+func EnumSetFoldDedupe(sep string, allowed []string, defaults ...string) Getter[[]string] {
+	return newEnumSet(sep, allowed, true, true, defaults)
+}
+
+type enumSet struct {
+	*multiSlice[string, []string]
+	Sep     string
+	Allowed []string
+	dedupe  bool
+}
+
+func newEnumSet(sep string, allowed []string, fold, unique bool, defaults []string) enumSet {
+	parse := func(s string) ([]string, error) {
+		var ret []string
+	next:
+		for _, tok := range strings.Split(s, sep) {
+			tok = strings.TrimSpace(tok)
+			for _, a := range allowed {
+				if tok == a || fold && strings.EqualFold(tok, a) {
+					ret = append(ret, a)
+					continue next
+				}
+			}
+			return nil, fmt.Errorf("invalid value %q, must be one of: %s", tok, strings.Join(allowed, ", "))
+		}
+		return ret, nil
+	}
+
+	var values []string
+	for _, d := range defaults {
+		v, err := parse(d)
+		if err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", d, err))
+		}
+		values = append(values, v...)
+	}
+
+	if unique {
+		values = dedupe(values)
+	}
+	return enumSet{
+		multiSlice: newMultiSlice(values, parse),
+		Sep:        sep,
+		Allowed:    allowed,
+		dedupe:     unique,
+	}
+}
+
+func (e enumSet) Set(s string) error {
+	if err := e.multiSlice.Set(s); err != nil {
+		return err
+	}
+	if e.dedupe {
+		*e.Value = dedupe(*e.Value)
+	}
+	return nil
+}
+
+func (e enumSet) AllowedValues() []string {
+	return e.Allowed
+}
+
+func (e enumSet) String() string {
+	if e.isNil() {
+		return "<nil>"
+	}
+	return strings.Join(*e.Value, e.Sep)
+}
+
+// dedupe removes duplicates from s in place, keeping the first occurrence.
+func dedupe(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	ret := s[:0]
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// StringOrFileOption configures StringOrFile.
+type StringOrFileOption func(*stringOrFile)
+
+// FromFS makes StringOrFile read files from fsys instead of the primary filesystem.
+// Paths are cleaned before use, so that "@./key.pem" is read as "key.pem".
+func FromFS(fsys fs.FS) StringOrFileOption {
+	return func(s *stringOrFile) {
+		s.FS = fsys
+	}
+}
+
+// StringOrFile returns a Getter for a string that can either be given literally
+// or, if the value starts with "@", read from the referenced file.
+// The contents of the file are trimmed of leading and trailing whitespace.
+//
+// Given "-key @./key.pem" the value is the contents of ./key.pem, but given
+// "-key abc" it is "abc". The default value is always used literally.
+//
+// When the value is read from a file, String returns the reference instead of
+// the contents, so that they are not leaked when printing the configuration.
+func StringOrFile(defaultValue string, opts ...StringOrFileOption) Getter[string] {
+	s := stringOrFile{
+		Value: &defaultValue,
+		Ref:   new(string),
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+type stringOrFile struct {
+	Value *string
+	Ref   *string
+	FS    fs.FS
+}
+
+func (s stringOrFile) Get() any {
+	return s.Value
+}
+
+func (s stringOrFile) Val() *string {
+	return s.Value
+}
+
+func (s stringOrFile) Set(v string) error {
+	if !strings.HasPrefix(v, "@") {
+		*s.Value = v
+		*s.Ref = ""
+		return nil
+	}
+
+	name := v[1:]
+	var data []byte
+	var err error
+	if s.FS != nil {
+		data, err = fs.ReadFile(s.FS, path.Clean(name))
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", name, err)
+	}
+
+	*s.Value = strings.TrimSpace(string(data))
+	*s.Ref = v
+	return nil
+}
+
+func (s stringOrFile) String() string {
+	if s.Value == nil {
+		return "<nil>"
+	}
+	if *s.Ref != "" {
+		return *s.Ref
+	}
+	return *s.Value
+}
+
+func (s stringOrFile) IsBoolFlag() bool {
+	return false
+}
+
+// PEMBundle returns a Getter that can parse and accumulate PEM encoded data, such
+// as a bundle of CA certificates, concatenating every block into a single []byte.
+//
+// Values are lists of entries separated by sep, each entry is either inline PEM
+// or, if it starts with "@", the name of a file to read it from, as in
+// "-ca @root.pem,@intermediate.pem". Files are read from fsys, or from the primary
+// filesystem if it is nil. Every entry must contain at least one PEM block and
+// nothing else.
+//
+// String reports the number of blocks instead of the data.
+// It panics if any given default cannot be parsed.
+func PEMBundle(sep string, fsys fs.FS, defaults ...string) Getter[[]byte] {
+	parse := func(s string) ([]byte, error) {
+		var ret []byte
+		for i, entry := range strings.Split(s, sep) {
+			entry = strings.TrimSpace(entry)
+			name := fmt.Sprintf("inline entry %d", i)
+			data := []byte(entry)
+			if strings.HasPrefix(entry, "@") {
+				name = strconv.Quote(entry)
+				var err error
+				if fsys != nil {
+					data, err = fs.ReadFile(fsys, path.Clean(entry[1:]))
+				} else {
+					data, err = os.ReadFile(entry[1:])
+				}
+				if err != nil {
+					return nil, fmt.Errorf("unable to read %q: %w", entry[1:], err)
+				}
+			}
+
+			n, rest := 0, data
+			for {
+				var block *pem.Block
+				if block, rest = pem.Decode(rest); block == nil {
+					break
+				}
+				ret = append(ret, pem.EncodeToMemory(block)...)
+				n++
+			}
+			if n == 0 || len(strings.TrimSpace(string(rest))) > 0 {
+				return nil, fmt.Errorf("invalid PEM in %s", name)
+			}
+		}
+		return ret, nil
+	}
+
+	var values []byte
+	for _, d := range defaults {
+		v, err := parse(d)
+		if err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", d, err))
+		}
+		values = append(values, v...)
+	}
+	return pemBundle{newMultiSlice(values, parse)}
+}
+
+type pemBundle struct {
+	*multiSlice[byte, []byte]
+}
+
+func (p pemBundle) String() string {
+	if p.isNil() {
+		return "<nil>"
+	}
+	if len(*p.Value) == 0 {
+		return ""
+	}
+
+	n, rest := 0, *p.Value
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		n++
+	}
+	if n == 1 {
+		return "1 PEM block"
+	}
+	return fmt.Sprintf("%d PEM blocks", n)
+}
+
+var byteUnits = []struct {
+	name string
+	size float64
+}{
+	{"B", 1},
+	{"kB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12}, {"PB", 1e15},
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40}, {"PiB", 1 << 50},
+}
+
+// parseByteSize parses sizes such as "10MB" or "1.5GiB" into bytes. Decimal (kB, MB, ...)
+// and binary (KiB, MiB, ...) units are supported, ignoring case. A number without
+// a unit is in bytes.
+func parseByteSize(s string) (float64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '-' || r == '+')
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.TrimSpace(s[i:])
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("invalid size %q, must not be negative", s)
+	}
+	if unit == "" {
+		return v, nil
+	}
+	for _, u := range byteUnits {
+		if strings.EqualFold(unit, u.name) {
+			return v * u.size, nil
+		}
+	}
+
+	names := make([]string, len(byteUnits))
+	for i, u := range byteUnits {
+		names[i] = u.name
+	}
+	return 0, fmt.Errorf("invalid size %q, unknown unit %q, must be one of: %s", s, unit, strings.Join(names, ", "))
+}
+
+// Rate returns a Getter that can parse transfer rates such as "10MB/s" or "500KiB/m"
+// into bytes per second.
+//
+// Sizes support decimal (kB, MB, ...) and binary (KiB, MiB, ...) units, ignoring
+// case, or no unit for bytes. The denominator must be one of "/s", "/m" or "/h".
+func Rate(defaultValue float64) Getter[float64] {
+	return rate{Value: &defaultValue}
+}
+
+type rate struct {
+	Value *float64
+}
+
+func (r rate) Get() any {
+	return r.Value
+}
+
+func (r rate) Val() *float64 {
+	return r.Value
+}
+
+func (r rate) Set(s string) error {
+	size, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return fmt.Errorf("invalid rate %q, must be in the form SIZE/UNIT", s)
+	}
+
+	v, err := parseByteSize(strings.TrimSpace(size))
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+
+	switch strings.TrimSpace(per) {
+	case "s":
+	case "m":
+		v /= 60
+	case "h":
+		v /= 3600
+	default:
+		return fmt.Errorf("invalid rate %q, unknown unit %q, must be one of: s, m, h", s, per)
+	}
+
+	*r.Value = v
+	return nil
+}
+
+// String returns the rate per second using the largest decimal unit that fits.
+func (r rate) String() string {
+	if r.Value == nil {
+		return "<nil>"
+	}
+
+	unit := byteUnits[0]
+	for _, u := range byteUnits[:6] {
+		if *r.Value >= u.size {
+			unit = u
+		}
+	}
+	return strconv.FormatFloat(*r.Value/unit.size, 'f', -1, 64) + unit.name + "/s"
+}
+
+func (r rate) IsBoolFlag() bool {
+	return false
+}
+
+// Seconds returns a Getter for a time.Duration that accepts either a duration
+// string, such as "2s500ms", or a bare number of seconds, such as "2.5".
+func Seconds(defaultValue time.Duration) Getter[time.Duration] {
+	return Var(defaultValue, set(parseSeconds))
+}
+
+// SecondsList returns a Getter that can parse and accumulate durations given
+// either as duration strings or bare numbers of seconds.
+func SecondsList(defaults ...time.Duration) Getter[[]time.Duration] {
+	return Slice(defaults, parseSeconds)
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid duration %q, must be a duration or a number of seconds", s)
+	}
+	secs := f * float64(time.Second)
+	// float64(math.MaxInt64) is 1<<63, which does not fit in a Duration.
+	if secs >= math.MaxInt64 || secs < math.MinInt64 {
+		return 0, fmt.Errorf("invalid duration %q, out of range", s)
+	}
+	return time.Duration(secs), nil
+}
+
+// Column is a single name=type pair of a Schema.
+type Column struct {
+	Name string
+	Type string
+}
+
+func (c Column) String() string {
+	return c.Name + "=" + c.Type
+}
+
+// SchemaOption configures a Schema.
+type SchemaOption func(*schemaOptions)
+
+type schemaOptions struct {
+	types       []string
+	uniqueNames bool
+}
+
+// AllowedTypes makes a Schema fail if a column has a type other than the given ones.
+func AllowedTypes(types ...string) SchemaOption {
+	return func(o *schemaOptions) {
+		o.types = types
+	}
+}
+
+// UniqueNames makes a Schema fail if a column name is given more than once.
+func UniqueNames() SchemaOption {
+	return func(o *schemaOptions) {
+		o.uniqueNames = true
+	}
+}
+
+// Schema returns a Getter that can parse and accumulate comma separated lists of
+// name=type column definitions, such as "id=int,name=string", preserving their order.
+//
+// By default any type is accepted and names can be repeated, see AllowedTypes
+// and UniqueNames.
+// It panics if any given default cannot be parsed.
+func Schema(defaults []string, opts ...SchemaOption) Getter[[]Column] {
+	var o schemaOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parse := func(s string) ([]Column, error) {
+		var ret []Column
+	next:
+		for _, tok := range strings.Split(s, ",") {
+			name, typ, ok := strings.Cut(tok, "=")
+			name, typ = strings.TrimSpace(name), strings.TrimSpace(typ)
+			if !ok || name == "" || typ == "" {
+				return nil, fmt.Errorf("invalid column %q, must be in the form name=type", tok)
+			}
+			if o.types == nil {
+				ret = append(ret, Column{Name: name, Type: typ})
+				continue
+			}
+			for _, t := range o.types {
+				if t == typ {
+					ret = append(ret, Column{Name: name, Type: typ})
+					continue next
+				}
+			}
+			return nil, fmt.Errorf("invalid type %q for column %q, must be one of: %s", typ, name, strings.Join(o.types, ", "))
+		}
+		return ret, nil
+	}
+
+	var values []Column
+	for _, d := range defaults {
+		v, err := parse(d)
+		if err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", d, err))
+		}
+		values = append(values, v...)
+	}
+
+	return schema{
+		multiSlice:  newMultiSlice(values, parse),
+		uniqueNames: o.uniqueNames,
+	}
+}
+
+type schema struct {
+	*multiSlice[Column, []Column]
+	uniqueNames bool
+}
+
+func (s schema) Set(v string) error {
+	if !s.uniqueNames {
+		return s.multiSlice.Set(v)
+	}
+
+	// the first Set reuses the storage of the defaults, so keep a copy to restore them
+	prev, written := append([]Column(nil), *s.Value...), s.written
+	if err := s.multiSlice.Set(v); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, c := range *s.Value {
+		if seen[c.Name] {
+			*s.Value, s.written = prev, written
+			return fmt.Errorf("duplicate column %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+func (s schema) String() string {
+	if s.isNil() {
+		return "<nil>"
+	}
+	cols := make([]string, len(*s.Value))
+	for i, c := range *s.Value {
+		cols[i] = c.String()
+	}
+	return strings.Join(cols, ",")
+}
+
+// DynamicEnum returns a Getter for a string that must be one of the values returned
+// by allowed. It is called once on every Set, so validation reflects the state at
+// the time of parsing. The default value is not validated.
+func DynamicEnum(defaultValue string, allowed func() []string) Getter[string] {
+	return dynamicEnum{
+		Value:   &defaultValue,
+		Allowed: allowed,
+	}
+}
+
+type dynamicEnum struct {
+	Value   *string
+	Allowed func() []string
+}
+
+func (d dynamicEnum) Get() any {
+	return d.Value
+}
+
+func (d dynamicEnum) Val() *string {
+	return d.Value
+}
+
+func (d dynamicEnum) Set(s string) error {
+	allowed := d.Allowed()
+	for _, a := range allowed {
+		if a == s {
+			*d.Value = s
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q, must be one of: %s", s, strings.Join(allowed, ", "))
+}
+
+func (d dynamicEnum) String() string {
+	if d.Value == nil {
+		return "<nil>"
+	}
+	return *d.Value
+}
+
+func (d dynamicEnum) AllowedValues() []string {
+	return d.Allowed()
+}
+
+func (d dynamicEnum) IsBoolFlag() bool {
+	return false
+}
+
+var siPrefixes = []struct {
+	name string
+	mult float64
+}{
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// SI returns a Getter that can parse numbers with an optional decimal SI suffix,
+// such as "1k", "-2.5M" or "1G" (1000, -2500000 and 1e9).
+//
+// The supported suffixes are k, M, G, T, P and E, they are case sensitive.
+// Unlike sizes, these are always powers of 1000, which is suitable for counts
+// and thresholds.
+func SI(defaultValue float64) Getter[float64] {
+	return si{Value: &defaultValue}
+}
+
+type si struct {
+	Value *float64
+}
+
+func (s si) Get() any {
+	return s.Value
+}
+
+func (s si) Val() *float64 {
+	return s.Value
+}
+
+func (s si) Set(v string) error {
+	num, mult := v, 1.0
+	for _, p := range siPrefixes {
+		if strings.HasSuffix(v, p.name) {
+			num, mult = strings.TrimSuffix(v, p.name), p.mult
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("invalid number %q, must be a number with an optional suffix, one of: k, M, G, T, P, E", v)
+	}
+	*s.Value = f * mult
+	return nil
+}
+
+// String returns the value using the largest suffix that fits.
+func (s si) String() string {
+	if s.Value == nil {
+		return "<nil>"
+	}
+
+	v, suffix := *s.Value, ""
+	for _, p := range siPrefixes {
+		if math.Abs(*s.Value) >= p.mult {
+			v, suffix = *s.Value/p.mult, p.name
+		}
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64) + suffix
+}
+
+func (s si) IsBoolFlag() bool {
+	return false
+}
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	Lo, Hi int
+}
+
+func (p PortRange) String() string {
+	if p.Lo == p.Hi {
+		return strconv.Itoa(p.Lo)
+	}
+	return fmt.Sprintf("%d-%d", p.Lo, p.Hi)
+}
+
+// PortRanges returns a Getter that can parse and accumulate inclusive port ranges
+// such as "8000-8999". A single port, such as "8080", is a range of one.
+// Ports must be in the range [1, 65535].
+// It panics if any given default cannot be parsed.
+func PortRanges(defaults ...string) Getter[[]PortRange] {
+	return MustSlice(defaults, parsePortRange)
+}
+
+func parsePortRange(s string) (PortRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+
+	var r PortRange
+	var err error
+	if r.Lo, err = parsePort(lo); err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	if r.Hi, err = parsePort(hi); err != nil {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	if r.Lo > r.Hi {
+		return PortRange{}, fmt.Errorf("invalid port range %q: %d is greater than %d", s, r.Lo, r.Hi)
+	}
+	return r, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %d, must be in range [1, 65535]", p)
+	}
+	return p, nil
+}
+
+// StructFlag defines one flag named "prefix.field" for every exported field of
+// T, all of them backed by a single struct value, initialized to defaultValue,
+// which is returned. This allows building a logical group of settings such as
+// "-db.host x -db.port 5432" into one value.
+//
+// Field names are lowercased unless a `flag:"name"` tag is given, fields tagged
+// with `flag:"-"` are skipped. The usage of each flag is taken from the `usage` tag.
+//
+// Supported field types are string, bool, all int, uint and float types, and
+// time.Duration. It panics if T is not a struct or has a field of any other type.
+func StructFlag[T any](set *Set, prefix string, defaultValue T) *T {
+	v := new(T)
+	*v = defaultValue
+
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Errorf("flag: StructFlag requires a struct, got %s", rv.Type()))
+	}
+
+	set.init()
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.frozen {
+		panic(fmt.Errorf("%w: cannot add flag %s", ErrFrozen, prefix))
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		if tag, ok := field.Tag.Lookup("flag"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		if !isStructFieldSupported(field.Type) {
+			panic(fmt.Errorf("flag: unsupported type %s for field %s", field.Type, field.Name))
+		}
+
+		set.addVar(prefix+"."+name, structField{rv.Field(i)}, field.Tag.Get("usage"), nil)
+	}
+
+	return v
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isStructFieldSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+var _ stdflag.Getter = structField{}
+
+// structField is a flag.Getter for a single field of a struct, Get returns its
+// address. It is not a Getter[T] as the value is accessed through the struct.
+type structField struct {
+	Field reflect.Value
+}
+
+func (f structField) Get() any {
+	return f.Field.Addr().Interface()
+}
+
+func (f structField) Set(s string) error {
+	t := f.Field.Type()
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.Field.SetInt(int64(d))
+
+	case t.Kind() == reflect.String:
+		f.Field.SetString(s)
+
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.Field.SetBool(b)
+
+	case f.Field.CanInt():
+		i, err := strconv.ParseInt(s, 0, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.Field.SetInt(i)
+
+	case f.Field.CanUint():
+		u, err := strconv.ParseUint(s, 0, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.Field.SetUint(u)
+
+	case f.Field.CanFloat():
+		fl, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return err
+		}
+		f.Field.SetFloat(fl)
+	}
+	return nil
+}
+
+func (f structField) String() string {
+	if !f.Field.IsValid() {
+		return "<nil>"
+	}
+	return fmt.Sprint(f.Field.Interface())
+}
+
+func (f structField) IsBoolFlag() bool {
+	return f.Field.Kind() == reflect.Bool
+}
+
+// Distribution returns a Getter for a comma separated list of weights, such as
+// "0.7,0.2,0.1", where every weight must be in the range [0, 1] and their sum
+// must be within epsilon of 1.
+//
+// Unlike other slices, every call to Set replaces the whole distribution.
+// It panics if the defaults are not a valid distribution, unless there are none.
+func Distribution(epsilon float64, defaults ...float64) Getter[[]float64] {
+	d := distribution{
+		Value:   &defaults,
+		Epsilon: epsilon,
+	}
+	if len(defaults) > 0 {
+		if err := d.validate(defaults); err != nil {
+			panic(fmt.Errorf("flag: invalid default value %v: %w", defaults, err))
+		}
+	}
+	return d
+}
+
+type distribution struct {
+	Value   *[]float64
+	Epsilon float64
+}
+
+func (d distribution) Get() any {
+	return d.Value
+}
+
+func (d distribution) Val() *[]float64 {
+	return d.Value
+}
+
+func (d distribution) Set(s string) error {
+	var weights []float64
+	for _, tok := range strings.Split(s, ",") {
+		w, err := parseFloat[float64](strings.TrimSpace(tok))
+		if err != nil {
+			return fmt.Errorf("invalid weight %q: %w", tok, err)
+		}
+		weights = append(weights, w)
+	}
+	if err := d.validate(weights); err != nil {
+		return err
+	}
+	*d.Value = weights
+	return nil
+}
+
+func (d distribution) validate(weights []float64) error {
+	var sum float64
+	for _, w := range weights {
+		if !(w >= 0 && w <= 1) {
+			return fmt.Errorf("invalid weight %v, must be in range [0, 1]", w)
+		}
+		sum += w
+	}
+	if math.Abs(sum-1) > d.Epsilon {
+		return fmt.Errorf("invalid distribution, weights must sum to 1 but they sum to %v", sum)
+	}
+	return nil
+}
+
+func (d distribution) String() string {
+	if d.Value == nil {
+		return "<nil>"
+	}
+	weights := make([]string, len(*d.Value))
+	for i, w := range *d.Value {
+		weights[i] = strconv.FormatFloat(w, 'f', -1, 64)
+	}
+	return strings.Join(weights, ",")
+}
+
+func (d distribution) IsBoolFlag() bool {
+	return false
+}
+
+// CronOption configures Cron.
+type CronOption func(*cronOptions)
+
+type cronOptions struct {
+	seconds bool
+}
+
+// CronSeconds makes Cron expect 6 fields, the first one being seconds.
+func CronSeconds() CronOption {
+	return func(o *cronOptions) {
+		o.seconds = true
+	}
+}
+
+// CronExpr is a parsed cron expression.
+type CronExpr struct {
+	Raw string
+
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+}
+
+func (c CronExpr) String() string {
+	return c.Raw
+}
+
+// Next returns the first time after t that matches the expression, in t's location.
+// It returns the zero time if the expression is empty or nothing matches within
+// the next 5 years.
+func (c CronExpr) Next(t time.Time) time.Time {
+	if c.Raw == "" {
+		return time.Time{}
+	}
+
+	// Hours, minutes and seconds are advanced in absolute time, time.Date would
+	// normalize a wall clock that falls in a DST gap or overlap to an earlier
+	// instant. Months and days use it to skip ahead, falling back to the next hour
+	// if the midnight they ask for does not exist.
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		loc := t.Location()
+		var next time.Time
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next = nextHour(t)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Duration(60-t.Second()) * time.Second)
+		case c.second&(1<<uint(t.Second())) == 0:
+			next = t.Add(time.Second)
+		default:
+			return t
+		}
+		if !next.After(t) {
+			next = nextHour(t)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// nextHour returns the start of the wall clock hour after t, t must be truncated
+// to the second.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(59-t.Minute())*time.Minute + time.Duration(60-t.Second())*time.Second)
+}
+
+// dayMatches follows the usual cron semantics, if both day of month and day
+// of week are restricted, matching either is enough.
+func (c CronExpr) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Cron returns a Getter that can parse standard 5 field cron expressions, or 6
+// field expressions if CronSeconds is given.
+//
+// Fields support "*", single values, ranges ("1-5"), steps ("*/15", "0-30/5")
+// and comma separated lists of those. Months and days of week may be given by
+// their three letter english names, day of week 7 is also sunday.
+//
+// It panics if defaultValue cannot be parsed, unless it's empty.
+func Cron(defaultValue string, opts ...CronOption) Getter[CronExpr] {
+	var o cronOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parse := func(s string) (CronExpr, error) {
+		return parseCron(s, o.seconds)
+	}
+	if defaultValue == "" {
+		return Var(CronExpr{}, set(parse))
+	}
+	return MustVar(defaultValue, set(parse))
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	cronDow    = cronField{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+func parseCron(s string, seconds bool) (CronExpr, error) {
+	fields := strings.Fields(s)
+	want := 5
+	if seconds {
+		want = 6
+	} else {
+		fields = append([]string{"0"}, fields...)
+	}
+	if len(fields) != 6 {
+		return CronExpr{}, fmt.Errorf("invalid cron expression %q, must have %d fields", s, want)
+	}
+
+	c := CronExpr{
+		Raw:     s,
+		domStar: fields[3] == "*" || fields[3] == "?",
+		dowStar: fields[5] == "*" || fields[5] == "?",
+	}
+	var err error
+	for i, f := range []struct {
+		field cronField
+		dst   *uint64
+	}{
+		{cronSecond, &c.second},
+		{cronMinute, &c.minute},
+		{cronHour, &c.hour},
+		{cronDom, &c.dom},
+		{cronMonth, &c.month},
+		{cronDow, &c.dow},
+	} {
+		if *f.dst, err = f.field.parse(fields[i]); err != nil {
+			return CronExpr{}, fmt.Errorf("invalid cron expression %q: %w", s, err)
+		}
+	}
+
+	// 7 is an alias for sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		lo, hi := f.min, f.max
+		if rng != "*" && rng != "?" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiStr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid %s field %q, %d is greater than %d", f.name, s, lo, hi)
+			}
+		}
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s field %q, invalid step %q", f.name, s, stepStr)
+			}
+			step = n
+		}
+
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s field value %q", f.name, s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s field value %d, must be in range [%d, %d]", f.name, n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// GlobPatterns is a list of glob patterns, as understood by [filepath.Match].
+type GlobPatterns []string
+
+// Matches reports whether path matches any of the patterns.
+//
+// Matching follows [filepath.Match], so "*" does not match separators and "**"
+// is equivalent to "*".
+func (g GlobPatterns) Matches(path string) bool {
+	for _, pattern := range g {
+		// patterns are validated by Set, so the error can't happen
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Globs returns a Getter that can parse and accumulate lists of glob patterns
+// separated by sep, validating that each one is well formed.
+// It panics if any given default is not a valid pattern.
+func Globs(sep string, defaults ...string) Getter[GlobPatterns] {
+	parse := func(s string) (GlobPatterns, error) {
+		var ret GlobPatterns
+		for _, pattern := range strings.Split(s, sep) {
+			pattern = strings.TrimSpace(pattern)
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+			ret = append(ret, pattern)
+		}
+		return ret, nil
+	}
+
+	var values GlobPatterns
+	for _, d := range defaults {
+		v, err := parse(d)
+		if err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", d, err))
+		}
+		values = append(values, v...)
+	}
+	return newMultiSlice(values, parse)
+}
+
+// TriState is a bool with an extra "auto" state, as in --color=auto|always|never.
+type TriState int
+
+// List of TriState values, the zero value is Auto.
+const (
+	Auto TriState = iota
+	Always
+	Never
+)
+
+// String returns "auto", "always" or "never".
+func (t TriState) String() string {
+	switch t {
+	case Always:
+		return "always"
+	case Never:
+		return "never"
+	default:
+		return "auto"
+	}
+}
+
+// Resolve collapses t into a bool, calling autoFn to decide if t is Auto.
+func (t TriState) Resolve(autoFn func() bool) bool {
+	switch t {
+	case Always:
+		return true
+	case Never:
+		return false
+	default:
+		return autoFn()
+	}
+}
+
+// Tristate returns a Getter that can parse "auto", "always" and "never", ignoring
+// case. The aliases "true", "yes" and "on" for always, and "false", "no" and "off"
+// for never are also accepted.
+//
+// Unlike Bool, the flag requires a value.
+func Tristate(defaultValue TriState) Getter[TriState] {
+	return Var(defaultValue, set(parseTriState))
+}
+
+func parseTriState(s string) (TriState, error) {
+	switch strings.ToLower(s) {
+	case "auto":
+		return Auto, nil
+	case "always", "true", "yes", "on":
+		return Always, nil
+	case "never", "false", "no", "off":
+		return Never, nil
+	}
+	return Auto, fmt.Errorf("invalid value %q, must be one of: auto, always, never", s)
+}
+
+// Triple holds the three values parsed by Tuple3.
+type Triple[A, B, C any] struct {
+	First  A
+	Second B
+	Third  C
+}
+
+// Tuple3 returns a Getter that splits values on sep into exactly three fields,
+// parsing each of them with its own parser, so that given "," and parsers for
+// float64, float64 and string, "1.5,2.0,label" becomes {1.5, 2, "label"}.
+// Use MapValue to convert the result into a more meaningful type.
+//
+// If defaultValue is provided it is parsed as the default, otherwise the default
+// is the zero Triple. It panics if more than one default is given or if it cannot be parsed.
+func Tuple3[A, B, C any](sep string, pa ValParser[A], pb ValParser[B], pc ValParser[C], defaultValue ...string) Getter[Triple[A, B, C]] {
+	parse := func(s string) (Triple[A, B, C], error) {
+		var ret Triple[A, B, C]
+		fields := strings.Split(s, sep)
+		if len(fields) != 3 {
+			return ret, fmt.Errorf("invalid tuple %q, must have 3 fields separated by %q but has %d", s, sep, len(fields))
+		}
+
+		var err error
+		if ret.First, err = pa(fields[0]); err != nil {
+			return ret, fmt.Errorf("invalid tuple %q, field 1: %w", s, err)
+		}
+		if ret.Second, err = pb(fields[1]); err != nil {
+			return ret, fmt.Errorf("invalid tuple %q, field 2: %w", s, err)
+		}
+		if ret.Third, err = pc(fields[2]); err != nil {
+			return ret, fmt.Errorf("invalid tuple %q, field 3: %w", s, err)
+		}
+		return ret, nil
+	}
+
+	switch len(defaultValue) {
+	case 0:
+		return tuple3[A, B, C]{Var(Triple[A, B, C]{}, set(parse)), sep}
+	case 1:
+		return tuple3[A, B, C]{MustVar(defaultValue[0], set(parse)), sep}
+	default:
+		panic("flag: Tuple3 accepts at most one default value")
+	}
+}
+
+type tuple3[A, B, C any] struct {
+	Getter[Triple[A, B, C]]
+	Sep string
+}
+
+func (t tuple3[A, B, C]) String() string {
+	if t.Getter == nil {
+		return "<nil>"
+	}
+	v := t.Val()
+	if v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(v.First) + t.Sep + fmt.Sprint(v.Second) + t.Sep + fmt.Sprint(v.Third)
+}
+
+// StringsMin returns a Getter that can parse and accumulate lists of strings
+// separated by sep, that must end up with at least n distinct values.
+//
+// Since the final count is only known once every source has been parsed, the
+// check is done by Parse trough Validator, applying to the defaults as well.
+func StringsMin(n int, sep string, defaults ...string) Getter[[]string] {
+	parse := func(s string) ([]string, error) {
+		return strings.Split(s, sep), nil
+	}
+	var values []string
+	for _, d := range defaults {
+		v, _ := parse(d)
+		values = append(values, v...)
+	}
+	return stringsMin{
+		multiSlice: newMultiSlice(values, parse),
+		Min:        n,
+	}
+}
+
+type stringsMin struct {
+	*multiSlice[string, []string]
+	Min int
+}
+
+func (s stringsMin) Validate() error {
+	distinct := make(map[string]bool, len(*s.Value))
+	for _, v := range *s.Value {
+		distinct[v] = true
+	}
+	if got := len(distinct); got < s.Min {
+		return fmt.Errorf("got %d distinct values, need at least %d", got, s.Min)
+	}
+	return nil
+}
+
+// IndirectValue is the value of Indirect, it records how the value was obtained.
+type IndirectValue struct {
+	Origin string // The name of the resolver used, or "literal".
+	Raw    string // The value as given.
+	Value  string // The resolved value.
+}
+
+// String returns the value as given, so that resolved values, which may be
+// secrets, are not leaked when printing the configuration.
+func (v IndirectValue) String() string {
+	return v.Raw
+}
+
+// Indirect returns a Getter for a string that is either given literally or
+// resolved from a reference. Values of the form "name:rest", where name is a
+// key in resolvers, are resolved by passing rest to the corresponding resolver,
+// for example, given a resolver named "env", "env:TOKEN" could read $TOKEN.
+//
+// Any other value, such as "localhost:8080" or "http://example.com", is used
+// literally. Values that would otherwise be resolved can be given literally with
+// the "literal:" prefix, as in "literal:env:TOKEN". The default value is always
+// used literally.
+//
+// It generalizes StringOrFile.
+func Indirect(defaultValue string, resolvers map[string]func(string) (string, error)) Getter[IndirectValue] {
+	parse := func(s string) (IndirectValue, error) {
+		name, rest, found := strings.Cut(s, ":")
+		if found && name == "literal" {
+			return IndirectValue{Origin: "literal", Raw: s, Value: rest}, nil
+		}
+
+		resolve, ok := resolvers[name]
+		if !found || !ok {
+			return IndirectValue{Origin: "literal", Raw: s, Value: s}, nil
+		}
+		v, err := resolve(rest)
+		if err != nil {
+			return IndirectValue{}, fmt.Errorf("cannot resolve %q: %w", s, err)
+		}
+		return IndirectValue{Origin: name, Raw: s, Value: v}, nil
+	}
+
+	return Var(IndirectValue{Origin: "literal", Raw: defaultValue, Value: defaultValue}, set(parse))
+}
+
+// FieldsBuilder builds a Getter for a single flag holding a set of named fields,
+// see Fields.
+type FieldsBuilder struct {
+	sep    string
+	fields []fieldSpec
+}
+
+type fieldSpec struct {
+	name     string
+	parse    func(string) (any, error)
+	required bool
+}
+
+// Fields returns a builder for a Getter that can parse lists of "name=value"
+// pairs separated by sep, where each field is parsed by its own parser, such as
+// "initial=100ms,max=10s,factor=2".
+//
+// The resulting value maps field names to their parsed values. Every value
+// replaces the previous one, so all required fields must be given at once.
+// Unknown and duplicated fields are an error.
+func Fields(sep string) *FieldsBuilder {
+	return &FieldsBuilder{sep: sep}
+}
+
+// Required registers a field that must be present, parsed with parse.
+func (b *FieldsBuilder) Required(name string, parse func(string) (any, error)) *FieldsBuilder {
+	b.fields = append(b.fields, fieldSpec{name: name, parse: parse, required: true})
+	return b
+}
+
+// Optional registers a field that may be omitted, parsed with parse.
+func (b *FieldsBuilder) Optional(name string, parse func(string) (any, error)) *FieldsBuilder {
+	b.fields = append(b.fields, fieldSpec{name: name, parse: parse})
+	return b
+}
+
+// Getter returns the Getter for the registered fields. If defaultValue is empty
+// the default is an empty map, otherwise it is parsed and it panics if it's not valid.
+func (b *FieldsBuilder) Getter(defaultValue string) Getter[map[string]any] {
+	// copy so that the builder can be reused
+	specs := append([]fieldSpec(nil), b.fields...)
+	sep := b.sep
+
+	parse := func(s string) (map[string]any, error) {
+		ret := make(map[string]any, len(specs))
+	next:
+		for _, tok := range strings.Split(s, sep) {
+			name, val, ok := strings.Cut(strings.TrimSpace(tok), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid field %q, must be in the form name=value", tok)
+			}
+			if _, dup := ret[name]; dup {
+				return nil, fmt.Errorf("duplicate field %q", name)
+			}
+			for _, spec := range specs {
+				if spec.name != name {
+					continue
+				}
+				v, err := spec.parse(val)
+				if err != nil {
+					return nil, fmt.Errorf("invalid field %q: %w", name, err)
+				}
+				ret[name] = v
+				continue next
+			}
+
+			names := make([]string, len(specs))
+			for i, spec := range specs {
+				names[i] = spec.name
+			}
+			return nil, fmt.Errorf("unknown field %q, must be one of: %s", name, strings.Join(names, ", "))
+		}
+
+		for _, spec := range specs {
+			if _, ok := ret[spec.name]; spec.required && !ok {
+				return nil, fmt.Errorf("missing required field %q", spec.name)
+			}
+		}
+		return ret, nil
+	}
+
+	var g Getter[map[string]any]
+	if defaultValue == "" {
+		g = Var(map[string]any{}, set(parse))
+	} else {
+		g = MustVar(defaultValue, set(parse))
+	}
+	return fieldsValue{Getter: g, specs: specs, sep: sep}
+}
+
+type fieldsValue struct {
+	Getter[map[string]any]
+	specs []fieldSpec
+	sep   string
+}
+
+// String prints the fields in the order they were registered.
+func (f fieldsValue) String() string {
+	if f.Getter == nil {
+		return "<nil>"
+	}
+	v := f.Val()
+	if v == nil {
+		return "<nil>"
+	}
+
+	var pairs []string
+	for _, spec := range f.specs {
+		if val, ok := (*v)[spec.name]; ok {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", spec.name, val))
+		}
+	}
+	return strings.Join(pairs, f.sep)
+}
+
+// QuantityValue is the value of Quantity.
+type QuantityValue struct {
+	Value  float64 // The normalized value, such as 0.5 for "500m".
+	Number string  // The number as given, without the suffix.
+	Suffix string  // The suffix as given, if any.
+}
+
+// String returns the quantity as given, such as "500m".
+func (q QuantityValue) String() string {
+	return q.Number + q.Suffix
+}
+
+// MilliValue returns the value multiplied by 1000 and rounded up, such as 500 for "500m".
+func (q QuantityValue) MilliValue() int64 {
+	return int64(math.Ceil(q.Value * 1000))
+}
+
+var quantitySuffixes = []struct {
+	name string
+	mult float64
+}{
+	{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+}
+
+// Quantity returns a Getter that can parse quantities as used by Kubernetes for
+// resources, such as "500m" (0.5), "1Gi" (1073741824) or "2.5".
+//
+// Numbers may be followed by a decimal suffix, one of n, u, m, k, M, G, T, P or E,
+// or a binary one, one of Ki, Mi, Gi, Ti, Pi or Ei. Suffixes are case sensitive.
+// It panics if defaultValue cannot be parsed.
+func Quantity(defaultValue string) Getter[QuantityValue] {
+	return MustVar(defaultValue, set(parseQuantity))
+}
+
+func parseQuantity(s string) (QuantityValue, error) {
+	i := len(s)
+	for i > 0 && ('a' <= s[i-1] && s[i-1] <= 'z' || 'A' <= s[i-1] && s[i-1] <= 'Z') {
+		i--
+	}
+	num, suffix := s[:i], s[i:]
+
+	mult := 1.0
+	if suffix != "" {
+		mult = 0
+		for _, q := range quantitySuffixes {
+			if q.name == suffix {
+				mult = q.mult
+				break
+			}
+		}
+		if mult == 0 {
+			var names []string
+			for _, q := range quantitySuffixes {
+				names = append(names, q.name)
+			}
+			return QuantityValue{}, fmt.Errorf("invalid quantity %q, unknown suffix %q, must be one of: %s", s, suffix, strings.Join(names, ", "))
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return QuantityValue{}, fmt.Errorf("invalid quantity %q, must be a number with an optional suffix", s)
+	}
+	return QuantityValue{Value: f * mult, Number: num, Suffix: suffix}, nil
+}
+
+// Ordering returns a Getter that can parse comma separated lists of items where
+// the order is meaningful, such as fallback priorities. Every item must be one
+// of allowed and can only appear once.
+//
+// Unlike EnumSet values do not accumulate, each value replaces the previous one.
+// It panics if the defaults are not valid.
+func Ordering(allowed []string, defaults ...string) Getter[[]string] {
+	parse := func(tok string) (string, string, error) { return tok, tok, nil }
+	format := func(s string) string { return s }
+	return newOrdered(allowed, defaults, "item", parse, format)
+}
+
+var _ Getter[[]string] = ordered[string]{}
+
+// ordered holds a list of items where the order is meaningful, as used by
+// Ordering and SortSpec. Every item is identified by a key that must be one of
+// Allowed and can only appear once.
+type ordered[T any] struct {
+	Value   *[]T
+	Allowed []string
+	noun    string                              // what an item is called in errors
+	parse   func(tok string) (string, T, error) // returns the key and the item
+	format  func(T) string
+}
+
+func newOrdered[T any](allowed, defaults []string, noun string, parse func(string) (string, T, error), format func(T) string) ordered[T] {
+	o := ordered[T]{Value: new([]T), Allowed: allowed, noun: noun, parse: parse, format: format}
+	if len(defaults) > 0 {
+		if err := o.Set(strings.Join(defaults, ",")); err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", strings.Join(defaults, ","), err))
+		}
+	}
+	return o
+}
+
+func (o ordered[T]) Get() any {
+	return o.Value
+}
+
+func (o ordered[T]) Val() *[]T {
+	return o.Value
+}
+
+func (o ordered[T]) Set(s string) error {
+	var ret []T
+	seen := make(map[string]bool)
+next:
+	for _, tok := range strings.Split(s, ",") {
+		key, item, err := o.parse(strings.TrimSpace(tok))
+		if err != nil {
+			return err
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate %s %q", o.noun, key)
+		}
+		for _, a := range o.Allowed {
+			if key == a {
+				seen[key] = true
+				ret = append(ret, item)
+				continue next
+			}
+		}
+		return fmt.Errorf("invalid %s %q, must be one of: %s", o.noun, key, strings.Join(o.Allowed, ", "))
+	}
+	*o.Value = ret
+	return nil
+}
+
+func (o ordered[T]) AllowedValues() []string {
+	return o.Allowed
+}
+
+func (o ordered[T]) String() string {
+	if o.Value == nil {
+		return "<nil>"
+	}
+	parts := make([]string, len(*o.Value))
+	for i, item := range *o.Value {
+		parts[i] = o.format(item)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (o ordered[T]) IsBoolFlag() bool {
+	return false
+}
+
+// IntStep returns a Getter that can parse values of type int that must be a
+// positive multiple of step, such as block sizes. Errors report the nearest
+// valid values.
+// It panics if step is not positive or if defaultValue is not a valid value.
+func IntStep(defaultValue, step int) Getter[int] {
+	return newStep(defaultValue, step, parseInt[int])
+}
+
+// Int64Step is like IntStep but for values of type int64.
+func Int64Step(defaultValue, step int64) Getter[int64] {
+	return newStep(defaultValue, step, parseInt[int64])
+}
+
+// UintStep is like IntStep but for values of type uint.
+func UintStep(defaultValue, step uint) Getter[uint] {
+	return newStep(defaultValue, step, parseUint[uint])
+}
+
+// Uint64Step is like IntStep but for values of type uint64.
+func Uint64Step(defaultValue, step uint64) Getter[uint64] {
+	return newStep(defaultValue, step, parseUint[uint64])
+}
+
+func newStep[T ~int | ~int64 | ~uint | ~uint64](defaultValue, step T, parse ValParser[T]) Getter[T] {
+	if step <= 0 {
+		panic(fmt.Errorf("flag: invalid step %v, must be positive", step))
+	}
+
+	check := func(v T) error {
+		if v > 0 && v%step == 0 {
+			return nil
+		}
+		lo := v - v%step
+		if lo <= 0 {
+			return fmt.Errorf("invalid value %v, must be a positive multiple of %v, nearest valid value is %v", v, step, step)
+		}
+		return fmt.Errorf("invalid value %v, must be a positive multiple of %v, nearest valid values are %v and %v", v, step, lo, lo+step)
+	}
+	if err := check(defaultValue); err != nil {
+		panic(fmt.Errorf("flag: invalid default value %v: %w", defaultValue, err))
+	}
+
+	return Var(defaultValue, set(func(s string) (T, error) {
+		v, err := parse(s)
+		if err != nil {
+			return 0, err
+		}
+		return v, check(v)
+	}))
+}
+
+// IntBase returns a Getter that can parse values of type int in the given base,
+// without a prefix, such as "1010" in base 2 or "ff" in base 16. Values are
+// printed in base 10.
+// It panics if base is not in the range [2, 36].
+func IntBase(defaultValue int, base int) Getter[int] {
+	return Var(defaultValue, set(parseIntBase(base)))
+}
+
+// IntsBase is like IntBase but it accumulates values.
+func IntsBase(base int, defaults ...int) Getter[[]int] {
+	return Slice(defaults, parseIntBase(base))
+}
+
+// UintBase is like IntBase but for values of type uint.
+func UintBase(defaultValue uint, base int) Getter[uint] {
+	return Var(defaultValue, set(parseUintBase(base)))
+}
+
+// UintsBase is like UintBase but it accumulates values.
+func UintsBase(base int, defaults ...uint) Getter[[]uint] {
+	return Slice(defaults, parseUintBase(base))
+}
+
+func checkBase(base int) {
+	if base < 2 || base > 36 {
+		panic(fmt.Errorf("flag: invalid base %d, must be in range [2, 36]", base))
+	}
+}
+
+func parseIntBase(base int) ValParser[int] {
+	checkBase(base)
+	return func(s string) (int, error) {
+		v, err := strconv.ParseInt(s, base, strconv.IntSize)
+		return int(v), err
+	}
+}
+
+func parseUintBase(base int) ValParser[uint] {
+	checkBase(base)
+	return func(s string) (uint, error) {
+		v, err := strconv.ParseUint(s, base, strconv.IntSize)
+		return uint(v), err
+	}
+}
+
+// URLOption configures URLList.
+type URLOption func(*urlOptions)
+
+type urlOptions struct {
+	absolute bool
+	schemes  []string
+}
+
+// URLAbsolute makes URLList reject relative URLs.
+func URLAbsolute() URLOption {
+	return func(o *urlOptions) {
+		o.absolute = true
+	}
+}
+
+// URLSchemes makes URLList reject URLs whose scheme is not one of schemes,
+// compared case insensitively. Since relative URLs have no scheme, it implies
+// URLAbsolute.
+func URLSchemes(schemes ...string) URLOption {
+	return func(o *urlOptions) {
+		o.absolute = true
+		o.schemes = append(o.schemes, schemes...)
+	}
+}
+
+// URLList returns a Getter that can parse and accumulate lists of URLs
+// separated by sep, as in "-endpoints https://a,https://b", validating each one
+// according to opts.
+// It panics if any given default does not satisfy opts.
+//
+// Since a variadic parameter must come last, opts are given as a slice:
+//
+//	flagr.URLList(",", []flagr.URLOption{flagr.URLSchemes("https")})
+func URLList(sep string, opts []URLOption, defaults ...*url.URL) Getter[[]*url.URL] {
+	var o urlOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for _, d := range defaults {
+		if err := o.validate(d); err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", d, err))
+		}
+	}
+
+	return newMultiSlice(defaults, func(s string) ([]*url.URL, error) {
+		var ret []*url.URL
+		for _, raw := range strings.Split(s, sep) {
+			raw = strings.TrimSpace(raw)
+			u, err := url.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid url %q: %w", raw, err)
+			}
+			if err := o.validate(u); err != nil {
+				return nil, fmt.Errorf("invalid url %q: %w", raw, err)
+			}
+			ret = append(ret, u)
+		}
+		return ret, nil
+	})
+}
+
+func (o urlOptions) validate(u *url.URL) error {
+	if o.absolute && !u.IsAbs() {
+		return errors.New("must be absolute")
+	}
+	if len(o.schemes) == 0 {
+		return nil
+	}
+	for _, scheme := range o.schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheme %q is not allowed, must be one of: %s", u.Scheme, strings.Join(o.schemes, ", "))
+}
+
+// DurationDefaultUnit returns a Getter that can parse values of type time.Duration
+// where bare numbers are taken to be in the given unit. With unit = time.Second,
+// "30" is 30s and "2.5" is 2.5s while "500ms" is still 500ms.
+// It panics if unit is not positive.
+func DurationDefaultUnit(unit, defaultValue time.Duration) Getter[time.Duration] {
+	if unit <= 0 {
+		panic(fmt.Errorf("flag: invalid unit %v, must be positive", unit))
+	}
+	return Var(defaultValue, set(func(s string) (time.Duration, error) {
+		// integers are handled separately so that they stay exact past 1<<53
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			d := time.Duration(n) * unit
+			if d/unit != time.Duration(n) {
+				return 0, fmt.Errorf("duration %q overflows when interpreted in %v", s, unit)
+			}
+			return d, nil
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.ParseDuration(s)
+		}
+		d := f * float64(unit)
+		if d >= math.MaxInt64 || d < math.MinInt64 {
+			return 0, fmt.Errorf("duration %q overflows when interpreted in %v", s, unit)
+		}
+		return time.Duration(d), nil
+	}))
+}
+
+// HostnameOption configures Hostname and Hostnames.
+type HostnameOption func(*hostnameOptions)
+
+type hostnameOptions struct {
+	trailingDot bool
+}
+
+// AllowTrailingDot makes Hostname and Hostnames accept fully qualified names ending
+// in a dot, such as "example.com.". The dot is kept in the value.
+func AllowTrailingDot() HostnameOption {
+	return func(o *hostnameOptions) {
+		o.trailingDot = true
+	}
+}
+
+// Hostname returns a Getter that can parse values of type string that must be
+// valid hostnames as per RFC 1123: at most 253 characters, made of dot separated
+// labels of 1 to 63 ascii letters, digits and hyphens, not starting or ending in
+// a hyphen.
+// It panics if defaultValue is not empty and is not a valid hostname.
+func Hostname(defaultValue string, opts ...HostnameOption) Getter[string] {
+	parse := parseHostname(opts)
+	if defaultValue != "" {
+		if _, err := parse(defaultValue); err != nil {
+			panic(fmt.Errorf("flag: invalid default value %q: %w", defaultValue, err))
+		}
+	}
+	return Var(defaultValue, set(parse))
+}
+
+// Hostnames returns a Getter that can parse and accumulate hostnames, as validated
+// by Hostname.
+// It panics if any given default is not a valid hostname.
+//
+// Since a variadic parameter must come last, opts are given as a slice.
+func Hostnames(opts []HostnameOption, defaults ...string) Getter[[]string] {
+	return MustSlice(defaults, parseHostname(opts))
+}
+
+func parseHostname(opts []HostnameOption) ValParser[string] {
+	var o hostnameOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(s string) (string, error) {
+		name := s
+		if strings.HasSuffix(name, ".") && len(name) > 1 {
+			if !o.trailingDot {
+				return "", fmt.Errorf("invalid hostname %q: trailing dot is not allowed", s)
+			}
+			name = name[:len(name)-1]
+		}
+		if name == "" {
+			return "", fmt.Errorf("invalid hostname %q: must not be empty", s)
+		}
+		if len(name) > 253 {
+			return "", fmt.Errorf("invalid hostname %q: length %d exceeds 253 characters", s, len(name))
+		}
+
+		for _, label := range strings.Split(name, ".") {
+			switch {
+			case label == "":
+				return "", fmt.Errorf("invalid hostname %q: empty label", s)
+			case len(label) > 63:
+				return "", fmt.Errorf("invalid hostname %q: label %q exceeds 63 characters", s, label)
+			case label[0] == '-' || label[len(label)-1] == '-':
+				return "", fmt.Errorf("invalid hostname %q: label %q must not start or end with a hyphen", s, label)
+			}
+			for _, c := range label {
+				if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+					return "", fmt.Errorf("invalid hostname %q: label %q contains invalid character %q", s, label, c)
+				}
+			}
+		}
+		return s, nil
+	}
+}
+
+// RetryPolicyValue is a retry policy with exponential backoff, as parsed by RetryPolicy.
+type RetryPolicyValue struct {
+	Attempts int           // The maximum number of attempts, always positive.
+	Base     time.Duration // The delay before the first retry.
+	Max      time.Duration // The maximum delay between retries, 0 means no limit.
+	Jitter   float64       // The fraction of the delay to randomize, in [0, 1].
+}
+
+// String returns the policy in its canonical form, such as "5x,base=200ms,max=5s,jitter=0.1".
+// Keys with a zero value are omitted. The zero RetryPolicyValue is the empty string.
+func (r RetryPolicyValue) String() string {
+	if r == (RetryPolicyValue{}) {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%dx", r.Attempts)
+	if r.Base != 0 {
+		fmt.Fprintf(&buf, ",base=%v", r.Base)
+	}
+	if r.Max != 0 {
+		fmt.Fprintf(&buf, ",max=%v", r.Max)
+	}
+	if r.Jitter != 0 {
+		fmt.Fprintf(&buf, ",jitter=%s", strconv.FormatFloat(r.Jitter, 'g', -1, 64))
+	}
+	return buf.String()
+}
+
+// RetryPolicy returns a Getter that can parse retry policies such as
+// "5x,base=200ms,max=5s,jitter=0.1": the number of attempts followed by "x",
+// then any of the keys base, max and jitter, in any order. Attempts must be
+// positive, durations must not be negative and jitter must be in [0, 1].
+//
+// If defaultValue is empty the default is the zero RetryPolicyValue,
+// otherwise it panics if defaultValue cannot be parsed.
+func RetryPolicy(defaultValue string) Getter[RetryPolicyValue] {
+	if defaultValue == "" {
+		return Var(RetryPolicyValue{}, set(parseRetryPolicy))
+	}
+	return MustVar(defaultValue, set(parseRetryPolicy))
+}
+
+func parseRetryPolicy(s string) (RetryPolicyValue, error) {
+	var ret RetryPolicyValue
+	parts := strings.Split(s, ",")
+
+	attempts := strings.TrimSpace(parts[0])
+	n, err := strconv.Atoi(strings.TrimSuffix(attempts, "x"))
+	if err != nil || !strings.HasSuffix(attempts, "x") {
+		return ret, fmt.Errorf("invalid retry policy %q, must start with the number of attempts, as in 5x", s)
+	}
+	if n <= 0 {
+		return ret, fmt.Errorf("invalid retry policy %q, attempts must be positive", s)
+	}
+	ret.Attempts = n
+
+	seen := make(map[string]bool)
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ret, fmt.Errorf("invalid retry policy %q, %q must be in the form key=value", s, part)
+		}
+		if seen[key] {
+			return ret, fmt.Errorf("invalid retry policy %q, duplicate key %q", s, key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "base", "max":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return ret, fmt.Errorf("invalid retry policy %q, invalid %s: %w", s, key, err)
+			}
+			if d < 0 {
+				return ret, fmt.Errorf("invalid retry policy %q, %s must not be negative", s, key)
+			}
+			if key == "base" {
+				ret.Base = d
+			} else {
+				ret.Max = d
+			}
+		case "jitter":
+			j, err := strconv.ParseFloat(val, 64)
+			if err != nil || !(j >= 0 && j <= 1) {
+				return ret, fmt.Errorf("invalid retry policy %q, jitter must be a number in [0, 1]", s)
+			}
+			ret.Jitter = j
+		default:
+			return ret, fmt.Errorf("invalid retry policy %q, unknown key %q, must be one of: base, max, jitter", s, key)
+		}
+	}
+
+	if ret.Max != 0 && ret.Max < ret.Base {
+		return ret, fmt.Errorf("invalid retry policy %q, max must not be less than base", s)
+	}
+	return ret, nil
+}
+
+// RatioValue is a ratio of two integers, such as 16:9, as parsed by Ratio.
+type RatioValue struct {
+	Num, Den int
+}
+
+// Float returns the ratio as a float64, such as 1.777... for 16:9.
+func (r RatioValue) Float() float64 {
+	return float64(r.Num) / float64(r.Den)
+}
+
+// String returns the ratio as "num:den". The zero RatioValue is the empty string.
+func (r RatioValue) String() string {
+	if r == (RatioValue{}) {
+		return ""
+	}
+	return strconv.Itoa(r.Num) + ":" + strconv.Itoa(r.Den)
+}
+
+// Ratio returns a Getter that can parse ratios in the form "num:den", such as
+// "16:9", where both parts are integers and den is not zero.
+//
+// If defaultValue is empty the default is the zero RatioValue, otherwise it
+// panics if defaultValue cannot be parsed.
+func Ratio(defaultValue string) Getter[RatioValue] {
+	if defaultValue == "" {
+		return Var(RatioValue{}, set(parseRatio))
+	}
+	return MustVar(defaultValue, set(parseRatio))
+}
+
+// Ratios returns a Getter that can parse and accumulate ratios, as parsed by Ratio.
+// It panics if any given default cannot be parsed.
+func Ratios(defaults ...string) Getter[[]RatioValue] {
+	return MustSlice(defaults, parseRatio)
+}
+
+func parseRatio(s string) (RatioValue, error) {
+	num, den, ok := strings.Cut(s, ":")
+	if !ok {
+		return RatioValue{}, fmt.Errorf("invalid ratio %q, must be in the form num:den", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(num))
+	if err != nil {
+		return RatioValue{}, fmt.Errorf("invalid ratio %q, numerator must be an integer", s)
+	}
+	d, err := strconv.Atoi(strings.TrimSpace(den))
+	if err != nil {
+		return RatioValue{}, fmt.Errorf("invalid ratio %q, denominator must be an integer", s)
+	}
+	if d == 0 {
+		return RatioValue{}, fmt.Errorf("invalid ratio %q, denominator must not be zero", s)
+	}
+	return RatioValue{Num: n, Den: d}, nil
+}
+
+// SortField is a single field of a sort specification, as parsed by SortSpec.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// String returns the field in canonical form, such as "name:asc".
+func (s SortField) String() string {
+	if s.Desc {
+		return s.Field + ":desc"
+	}
+	return s.Field + ":asc"
+}
+
+// SortSpec returns a Getter that can parse comma separated sort specifications
+// such as "name:asc,created:desc", preserving their order. Each item is a field,
+// which must be one of allowed and can only appear once, optionally followed by
+// ":asc" (the default) or ":desc".
+//
+// Like Ordering, values do not accumulate, each value replaces the previous one.
+// It panics if the defaults are not valid.
+func SortSpec(allowed []string, defaults ...string) Getter[[]SortField] {
+	return newOrdered(allowed, defaults, "sort field", parseSortField, SortField.String)
+}
+
+var _ Getter[[]SortField] = ordered[SortField]{}
+
+func parseSortField(tok string) (string, SortField, error) {
+	field, dir, _ := strings.Cut(tok, ":")
+	f := SortField{Field: field}
+	switch dir {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return "", SortField{}, fmt.Errorf("invalid sort direction %q for field %q, must be one of: asc, desc", dir, field)
+	}
+	return field, f, nil
+}
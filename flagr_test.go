@@ -2,14 +2,22 @@ package flagr_test
 
 import (
 	"bytes"
+	"encoding/pem"
 	"errors"
 	"flag"
+	"io/fs"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/netip"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/flga/flagr"
@@ -485,58 +493,95 @@ func TestDefaults(t *testing.T) {
 	}
 
 	want := testflags.Flags{
-		Int:             ptr(defaults.Int),
-		Ints:            ptr(defaults.Ints),
-		Int8:            ptr(defaults.Int8),
-		Int8s:           ptr(defaults.Int8s),
-		Int16:           ptr(defaults.Int16),
-		Int16s:          ptr(defaults.Int16s),
-		Int32:           ptr(defaults.Int32),
-		Int32s:          ptr(defaults.Int32s),
-		Int64:           ptr(defaults.Int64),
-		Int64s:          ptr(defaults.Int64s),
-		Uint:            ptr(defaults.Uint),
-		Uints:           ptr(defaults.Uints),
-		Uint8:           ptr(defaults.Uint8),
-		Uint8s:          ptr(defaults.Uint8s),
-		Uint16:          ptr(defaults.Uint16),
-		Uint16s:         ptr(defaults.Uint16s),
-		Uint32:          ptr(defaults.Uint32),
-		Uint32s:         ptr(defaults.Uint32s),
-		Uint64:          ptr(defaults.Uint64),
-		Uint64s:         ptr(defaults.Uint64s),
-		Float32:         ptr(defaults.Float32),
-		Float32s:        ptr(defaults.Float32s),
-		Float64:         ptr(defaults.Float64),
-		Float64s:        ptr(defaults.Float64s),
-		Complex64:       ptr(defaults.Complex64),
-		Complex64s:      ptr(defaults.Complex64s),
-		Complex128:      ptr(defaults.Complex128),
-		Complex128s:     ptr(defaults.Complex128s),
-		Bool:            ptr(defaults.Bool),
-		Bools:           ptr(defaults.Bools),
-		String:          ptr(defaults.String),
-		Strings:         ptr(defaults.Strings),
-		Duration:        ptr(defaults.Duration),
-		Durations:       ptr(defaults.Durations),
-		Time:            ptr(defaults.Time),
-		MustTime:        ptr(defaults.Time),
-		Times:           ptr(defaults.Times),
-		MustTimes:       ptr(defaults.Times),
-		URL:             ptr(defaults.URL),
-		MustURL:         ptr(defaults.URL),
-		URLs:            ptr(defaults.URLs),
-		MustURLs:        ptr(defaults.URLs),
-		IPAddr:          ptr(defaults.IPAddr),
-		MustIPAddr:      ptr(defaults.IPAddr),
-		IPAddrs:         ptr(defaults.IPAddrs),
-		MustIPAddrs:     ptr(defaults.IPAddrs),
-		IPAddrPort:      ptr(defaults.IPAddrPort),
-		MustIPAddrPort:  ptr(defaults.IPAddrPort),
-		IPAddrPorts:     ptr(defaults.IPAddrPorts),
-		MustIPAddrPorts: ptr(defaults.IPAddrPorts),
-	}
-	if diff := cmp.Diff(want, vals, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{})); diff != "" {
+		Int:                 ptr(defaults.Int),
+		Ints:                ptr(defaults.Ints),
+		Int8:                ptr(defaults.Int8),
+		Int8s:               ptr(defaults.Int8s),
+		Int16:               ptr(defaults.Int16),
+		Int16s:              ptr(defaults.Int16s),
+		Int32:               ptr(defaults.Int32),
+		Int32s:              ptr(defaults.Int32s),
+		Int64:               ptr(defaults.Int64),
+		Int64s:              ptr(defaults.Int64s),
+		Uint:                ptr(defaults.Uint),
+		Uints:               ptr(defaults.Uints),
+		Uint8:               ptr(defaults.Uint8),
+		Uint8s:              ptr(defaults.Uint8s),
+		Uint16:              ptr(defaults.Uint16),
+		Uint16s:             ptr(defaults.Uint16s),
+		Uint32:              ptr(defaults.Uint32),
+		Uint32s:             ptr(defaults.Uint32s),
+		Uint64:              ptr(defaults.Uint64),
+		Uint64s:             ptr(defaults.Uint64s),
+		Float32:             ptr(defaults.Float32),
+		Float32s:            ptr(defaults.Float32s),
+		Float64:             ptr(defaults.Float64),
+		Float64s:            ptr(defaults.Float64s),
+		Complex64:           ptr(defaults.Complex64),
+		Complex64s:          ptr(defaults.Complex64s),
+		Complex128:          ptr(defaults.Complex128),
+		Complex128s:         ptr(defaults.Complex128s),
+		Bool:                ptr(defaults.Bool),
+		Bools:               ptr(defaults.Bools),
+		String:              ptr(defaults.String),
+		Strings:             ptr(defaults.Strings),
+		Duration:            ptr(defaults.Duration),
+		Durations:           ptr(defaults.Durations),
+		Time:                ptr(defaults.Time),
+		MustTime:            ptr(defaults.Time),
+		Times:               ptr(defaults.Times),
+		MustTimes:           ptr(defaults.Times),
+		URL:                 ptr(defaults.URL),
+		MustURL:             ptr(defaults.URL),
+		URLs:                ptr(defaults.URLs),
+		MustURLs:            ptr(defaults.URLs),
+		IPAddr:              ptr(defaults.IPAddr),
+		MustIPAddr:          ptr(defaults.IPAddr),
+		IPAddrs:             ptr(defaults.IPAddrs),
+		MustIPAddrs:         ptr(defaults.IPAddrs),
+		IPAddrPort:          ptr(defaults.IPAddrPort),
+		MustIPAddrPort:      ptr(defaults.IPAddrPort),
+		IPAddrPorts:         ptr(defaults.IPAddrPorts),
+		MustIPAddrPorts:     ptr(defaults.IPAddrPorts),
+		MAC:                 ptr(defaults.MAC),
+		MustMAC:             ptr(defaults.MAC),
+		MACs:                ptr(defaults.MACs),
+		MustMACs:            ptr(defaults.MACs),
+		IntRanges:           ptr(defaults.IntRanges),
+		BoolOrDuration:      ptr(defaults.BoolOrDuration),
+		FeatureSet:          ptr(map[string]bool{"asd": false, "dsa": false}),
+		PrefixSet:           ptr(defaults.PrefixSet),
+		KeyValues:           ptr([]flagr.KeyValue{{Key: "asd", Value: "1"}, {Key: "dsa", Value: "2"}}),
+		StringValidated:     ptr(defaults.StringValidated),
+		StringsValidated:    ptr(defaults.StringsValidated),
+		SemVer:              ptr(flagr.Version{Major: 4, Minor: 2}),
+		SemVers:             ptr([]flagr.Version{{Major: 4, Minor: 2}, {Major: 2, Minor: 4}}),
+		TimeOfDay:           ptr(flagr.Clock{Hour: 4, Minute: 2}),
+		TimesOfDay:          ptr([]flagr.Clock{{Hour: 4, Minute: 2}, {Hour: 2, Minute: 4}}),
+		EnumSet:             ptr(defaults.EnumSet),
+		EnumSetDedupe:       ptr(defaults.EnumSetDedupe),
+		StringOrFile:        ptr(defaults.StringOrFile),
+		Rate:                ptr(defaults.Rate),
+		Seconds:             ptr(defaults.Seconds),
+		SecondsList:         ptr(defaults.SecondsList),
+		Schema:              ptr([]flagr.Column{{Name: "asd", Type: "int"}, {Name: "dsa", Type: "string"}}),
+		SI:                  ptr(defaults.SI),
+		PortRanges:          ptr([]flagr.PortRange{{Lo: 42, Hi: 4242}, {Lo: 24, Hi: 24}}),
+		Distribution:        ptr(defaults.Distribution),
+		Cron:                ptr(flagr.CronExpr{Raw: defaults.Cron}),
+		Tristate:            ptr(defaults.Tristate),
+		Indirect:            ptr(flagr.IndirectValue{Origin: "literal", Raw: "asd", Value: "asd"}),
+		Quantity:            ptr(flagr.QuantityValue{Value: 0.5, Number: "500", Suffix: "m"}),
+		Ordering:            ptr(defaults.Ordering),
+		IntStep:             ptr(defaults.IntStep),
+		UintStep:            ptr(defaults.UintStep),
+		IntBase:             ptr(defaults.IntBase),
+		UintBase:            ptr(defaults.UintBase),
+		DurationDefaultUnit: ptr(defaults.DurationDefaultUnit),
+		Tuple3:              ptr(flagr.Triple[int, int, string]{First: 4, Second: 2, Third: "asd"}),
+		Fields:              ptr(map[string]any{"asd": 4}),
+	}
+	if diff := cmp.Diff(want, vals, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{}, netip.Prefix{}, flagr.CronExpr{})); diff != "" {
 		t.Errorf("mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -595,64 +640,138 @@ func TestSetters(t *testing.T) {
 		"-a47", "0.0.0.0:80",
 		"-a48", "0.0.0.0:80", "-a48", "0.0.0.0:81", "-a48", "0.0.0.0:82",
 		"-a49", "0.0.0.0:80", "-a49", "0.0.0.0:81", "-a49", "0.0.0.0:82",
+		"-a50", "00:00:00:00:00:01",
+		"-a51", "00:00:00:00:00:01",
+		"-a52", "00:00:00:00:00:01", "-a52", "00:00:00:00:00:02", "-a52", "00:00:00:00:00:03",
+		"-a53", "00:00:00:00:00:01", "-a53", "00:00:00:00:00:02", "-a53", "00:00:00:00:00:03",
+		"-a54", "1-3", "-a54", "5",
+		"-a55=5m",
+		"-a56", "asd",
+		"-a57", "10.1.0.0/16", "-a57", "172.16.0.0/12",
+		"-a58", "qwe=1", "-a58", "rty",
+		"-a59", "qwe",
+		"-a60", "qwe", "-a60", "rty", "-a60", "uio",
+		"-a61", "1.2.3-rc.1+b5",
+		"-a62", "1.0.0", "-a62", "2.0.0", "-a62", "3.0.0",
+		"-a63", "13:45:30",
+		"-a64", "01:00", "-a64", "02:00", "-a64", "03:00",
+		"-a65", "qwe,asd", "-a65", "qwe",
+		"-a66", "qwe,asd", "-a66", "qwe",
+		"-a67", "qwe",
+		"-a68", "10MB/s",
+		"-a69", "1.5",
+		"-a70", "1", "-a70", "2s", "-a70", "0.5",
+		"-a71", "qwe=int,rty=bool", "-a71", "uio=string",
+		"-a72", "2.5k",
+		"-a73", "1-10", "-a73", "80", "-a73", "8000-8999",
+		"-a74", "0.25,0.75",
+		"-a75", "0 9 * * mon-fri",
+		"-a76", "never",
+		"-a77", "upper:qwe",
+		"-a78", "2Ki",
+		"-a79", "qwe,asd",
+		"-a80", "12",
+		"-a81", "12",
+		"-a82", "ff",
+		"-a83", "17",
+		"-a84", "2.5",
+		"-a85", "1,2,qwe",
+		"-a86", "asd=1,dsa=qwe",
 	}
 	if err := s.Parse(args); err != nil {
 		t.Fatal(err)
 	}
 
 	want := testflags.Flags{
-		Int:             ptr(int(1)),
-		Ints:            ptr([]int{1, 2, 3}),
-		Int8:            ptr(int8(1)),
-		Int8s:           ptr([]int8{1, 2, 3}),
-		Int16:           ptr(int16(1)),
-		Int16s:          ptr([]int16{1, 2, 3}),
-		Int32:           ptr(int32(1)),
-		Int32s:          ptr([]int32{1, 2, 3}),
-		Int64:           ptr(int64(1)),
-		Int64s:          ptr([]int64{1, 2, 3}),
-		Uint:            ptr(uint(1)),
-		Uints:           ptr([]uint{1, 2, 3}),
-		Uint8:           ptr(uint8(1)),
-		Uint8s:          ptr([]uint8{1, 2, 3}),
-		Uint16:          ptr(uint16(1)),
-		Uint16s:         ptr([]uint16{1, 2, 3}),
-		Uint32:          ptr(uint32(1)),
-		Uint32s:         ptr([]uint32{1, 2, 3}),
-		Uint64:          ptr(uint64(1)),
-		Uint64s:         ptr([]uint64{1, 2, 3}),
-		Float32:         ptr(float32(1)),
-		Float32s:        ptr([]float32{1, 2, 3}),
-		Float64:         ptr(float64(1)),
-		Float64s:        ptr([]float64{1, 2, 3}),
-		Complex64:       ptr(complex64(1i)),
-		Complex64s:      ptr([]complex64{1i, 2i}),
-		Complex128:      ptr(complex128(1i)),
-		Complex128s:     ptr([]complex128{1i, 2i}),
-		Bool:            ptr(false),
-		Bools:           ptr([]bool{false, true, false}),
-		String:          ptr("qwe"),
-		Strings:         ptr([]string{"qwe", "rty", "uio"}),
-		Duration:        ptr(1 * time.Second),
-		Durations:       ptr([]time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}),
-		Time:            ptr(testflags.MustTime("0000-01-01")),
-		MustTime:        ptr(testflags.MustTime("0000-01-01")),
-		Times:           ptr([]time.Time{testflags.MustTime("0000-01-01"), testflags.MustTime("0000-01-02"), testflags.MustTime("0000-01-03")}),
-		MustTimes:       ptr([]time.Time{testflags.MustTime("0000-01-01"), testflags.MustTime("0000-01-02"), testflags.MustTime("0000-01-03")}),
-		URL:             ptr(testflags.MustURL("https://a.com")),
-		MustURL:         ptr(testflags.MustURL("https://a.com")),
-		URLs:            ptr([]*url.URL{testflags.MustURL("https://a.com"), testflags.MustURL("https://b.com"), testflags.MustURL("https://c.com")}),
-		MustURLs:        ptr([]*url.URL{testflags.MustURL("https://a.com"), testflags.MustURL("https://b.com"), testflags.MustURL("https://c.com")}),
-		IPAddr:          ptr(netip.MustParseAddr("0.0.0.0")),
-		MustIPAddr:      ptr(netip.MustParseAddr("0.0.0.0")),
-		IPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.2")}),
-		MustIPAddrs:     ptr([]netip.Addr{netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.2")}),
-		IPAddrPort:      ptr(netip.MustParseAddrPort("0.0.0.0:80")),
-		MustIPAddrPort:  ptr(netip.MustParseAddrPort("0.0.0.0:80")),
-		IPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("0.0.0.0:80"), netip.MustParseAddrPort("0.0.0.0:81"), netip.MustParseAddrPort("0.0.0.0:82")}),
-		MustIPAddrPorts: ptr([]netip.AddrPort{netip.MustParseAddrPort("0.0.0.0:80"), netip.MustParseAddrPort("0.0.0.0:81"), netip.MustParseAddrPort("0.0.0.0:82")}),
-	}
-	if diff := cmp.Diff(want, vals, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{})); diff != "" {
+		Int:                 ptr(int(1)),
+		Ints:                ptr([]int{1, 2, 3}),
+		Int8:                ptr(int8(1)),
+		Int8s:               ptr([]int8{1, 2, 3}),
+		Int16:               ptr(int16(1)),
+		Int16s:              ptr([]int16{1, 2, 3}),
+		Int32:               ptr(int32(1)),
+		Int32s:              ptr([]int32{1, 2, 3}),
+		Int64:               ptr(int64(1)),
+		Int64s:              ptr([]int64{1, 2, 3}),
+		Uint:                ptr(uint(1)),
+		Uints:               ptr([]uint{1, 2, 3}),
+		Uint8:               ptr(uint8(1)),
+		Uint8s:              ptr([]uint8{1, 2, 3}),
+		Uint16:              ptr(uint16(1)),
+		Uint16s:             ptr([]uint16{1, 2, 3}),
+		Uint32:              ptr(uint32(1)),
+		Uint32s:             ptr([]uint32{1, 2, 3}),
+		Uint64:              ptr(uint64(1)),
+		Uint64s:             ptr([]uint64{1, 2, 3}),
+		Float32:             ptr(float32(1)),
+		Float32s:            ptr([]float32{1, 2, 3}),
+		Float64:             ptr(float64(1)),
+		Float64s:            ptr([]float64{1, 2, 3}),
+		Complex64:           ptr(complex64(1i)),
+		Complex64s:          ptr([]complex64{1i, 2i}),
+		Complex128:          ptr(complex128(1i)),
+		Complex128s:         ptr([]complex128{1i, 2i}),
+		Bool:                ptr(false),
+		Bools:               ptr([]bool{false, true, false}),
+		String:              ptr("qwe"),
+		Strings:             ptr([]string{"qwe", "rty", "uio"}),
+		Duration:            ptr(1 * time.Second),
+		Durations:           ptr([]time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}),
+		Time:                ptr(testflags.MustTime("0000-01-01")),
+		MustTime:            ptr(testflags.MustTime("0000-01-01")),
+		Times:               ptr([]time.Time{testflags.MustTime("0000-01-01"), testflags.MustTime("0000-01-02"), testflags.MustTime("0000-01-03")}),
+		MustTimes:           ptr([]time.Time{testflags.MustTime("0000-01-01"), testflags.MustTime("0000-01-02"), testflags.MustTime("0000-01-03")}),
+		URL:                 ptr(testflags.MustURL("https://a.com")),
+		MustURL:             ptr(testflags.MustURL("https://a.com")),
+		URLs:                ptr([]*url.URL{testflags.MustURL("https://a.com"), testflags.MustURL("https://b.com"), testflags.MustURL("https://c.com")}),
+		MustURLs:            ptr([]*url.URL{testflags.MustURL("https://a.com"), testflags.MustURL("https://b.com"), testflags.MustURL("https://c.com")}),
+		IPAddr:              ptr(netip.MustParseAddr("0.0.0.0")),
+		MustIPAddr:          ptr(netip.MustParseAddr("0.0.0.0")),
+		IPAddrs:             ptr([]netip.Addr{netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.2")}),
+		MustIPAddrs:         ptr([]netip.Addr{netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.2")}),
+		IPAddrPort:          ptr(netip.MustParseAddrPort("0.0.0.0:80")),
+		MustIPAddrPort:      ptr(netip.MustParseAddrPort("0.0.0.0:80")),
+		IPAddrPorts:         ptr([]netip.AddrPort{netip.MustParseAddrPort("0.0.0.0:80"), netip.MustParseAddrPort("0.0.0.0:81"), netip.MustParseAddrPort("0.0.0.0:82")}),
+		MustIPAddrPorts:     ptr([]netip.AddrPort{netip.MustParseAddrPort("0.0.0.0:80"), netip.MustParseAddrPort("0.0.0.0:81"), netip.MustParseAddrPort("0.0.0.0:82")}),
+		MAC:                 ptr(testflags.MustMAC("00:00:00:00:00:01")),
+		MustMAC:             ptr(testflags.MustMAC("00:00:00:00:00:01")),
+		MACs:                ptr([]net.HardwareAddr{testflags.MustMAC("00:00:00:00:00:01"), testflags.MustMAC("00:00:00:00:00:02"), testflags.MustMAC("00:00:00:00:00:03")}),
+		MustMACs:            ptr([]net.HardwareAddr{testflags.MustMAC("00:00:00:00:00:01"), testflags.MustMAC("00:00:00:00:00:02"), testflags.MustMAC("00:00:00:00:00:03")}),
+		IntRanges:           ptr([]int{1, 2, 3, 5}),
+		BoolOrDuration:      ptr(flagr.Toggle{Enabled: true, TTL: 5 * time.Minute}),
+		FeatureSet:          ptr(map[string]bool{"asd": true, "dsa": false}),
+		PrefixSet:           ptr([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("172.16.0.0/12")}),
+		KeyValues:           ptr([]flagr.KeyValue{{Key: "qwe", Value: "1"}, {Key: "rty"}}),
+		StringValidated:     ptr("qwe"),
+		StringsValidated:    ptr([]string{"qwe", "rty", "uio"}),
+		SemVer:              ptr(flagr.Version{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1", Build: "b5"}),
+		SemVers:             ptr([]flagr.Version{{Major: 1}, {Major: 2}, {Major: 3}}),
+		TimeOfDay:           ptr(flagr.Clock{Hour: 13, Minute: 45, Second: 30}),
+		TimesOfDay:          ptr([]flagr.Clock{{Hour: 1}, {Hour: 2}, {Hour: 3}}),
+		EnumSet:             ptr([]string{"qwe", "asd", "qwe"}),
+		EnumSetDedupe:       ptr([]string{"qwe", "asd"}),
+		StringOrFile:        ptr("qwe"),
+		Rate:                ptr(float64(10e6)),
+		Seconds:             ptr(1500 * time.Millisecond),
+		SecondsList:         ptr([]time.Duration{time.Second, 2 * time.Second, 500 * time.Millisecond}),
+		Schema:              ptr([]flagr.Column{{Name: "qwe", Type: "int"}, {Name: "rty", Type: "bool"}, {Name: "uio", Type: "string"}}),
+		SI:                  ptr(float64(2500)),
+		PortRanges:          ptr([]flagr.PortRange{{Lo: 1, Hi: 10}, {Lo: 80, Hi: 80}, {Lo: 8000, Hi: 8999}}),
+		Distribution:        ptr([]float64{0.25, 0.75}),
+		Cron:                ptr(flagr.CronExpr{Raw: "0 9 * * mon-fri"}),
+		Tristate:            ptr(flagr.Never),
+		Indirect:            ptr(flagr.IndirectValue{Origin: "upper", Raw: "upper:qwe", Value: "QWE"}),
+		Quantity:            ptr(flagr.QuantityValue{Value: 2048, Number: "2", Suffix: "Ki"}),
+		Ordering:            ptr([]string{"qwe", "asd"}),
+		IntStep:             ptr(int(12)),
+		UintStep:            ptr(uint(12)),
+		IntBase:             ptr(int(255)),
+		UintBase:            ptr(uint(15)),
+		DurationDefaultUnit: ptr(2500 * time.Millisecond),
+		Tuple3:              ptr(flagr.Triple[int, int, string]{First: 1, Second: 2, Third: "qwe"}),
+		Fields:              ptr(map[string]any{"asd": 1, "dsa": "qwe"}),
+	}
+	if diff := cmp.Diff(want, vals, cmpopts.IgnoreUnexported(netip.Addr{}, netip.AddrPort{}, netip.Prefix{}, flagr.CronExpr{})); diff != "" {
 		t.Errorf("mismatch (-want +got):\n%s", diff)
 	}
 }
@@ -685,3 +804,3326 @@ func TestDoesntClobberDefault(t *testing.T) {
 func ptr[T any](t T) *T {
 	return &t
 }
+
+func TestLevel(t *testing.T) {
+	names := []string{"debug", "info", "warn", "error"}
+
+	t.Run("default", func(t *testing.T) {
+		var set flagr.Set
+		lvl := flagr.Add(&set, "level", flagr.Level(1, names), "")
+		if err := set.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if want := 1; *lvl != want {
+			t.Errorf("level = %v, want %v", *lvl, want)
+		}
+		if want, got := "info", set.Lookup("level").Value.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("matches names case-insensitively", func(t *testing.T) {
+		var set flagr.Set
+		lvl := flagr.Add(&set, "level", flagr.Level(1, names), "")
+		if err := set.Parse([]string{"-level", "WaRn"}); err != nil {
+			t.Fatal(err)
+		}
+		if want := 2; *lvl != want {
+			t.Errorf("level = %v, want %v", *lvl, want)
+		}
+		if want, got := "warn", set.Lookup("level").Value.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("fails on invalid name", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		flagr.Add(set, "level", flagr.Level(1, names), "")
+		err := set.Parse([]string{"-level", "verbose"})
+		if err == nil {
+			t.Fatal("err is nil")
+		}
+		if want := "debug, info, warn, error"; !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to contain %q", err, want)
+		}
+	})
+
+	t.Run("panics on invalid default", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("did not panic")
+			}
+		}()
+		flagr.Level(4, names)
+	})
+}
+
+func TestIsRepeatable(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "int", flagr.Int(0), "")
+	flagr.Add(&set, "ints", flagr.Ints(), "")
+	flagr.Add(&set, "url", flagr.MustURL("https://go.dev"), "")
+	flagr.Add(&set, "urls", flagr.MustURLs(), "")
+	flagr.Add(&set, "level", flagr.Level(0, []string{"a"}), "")
+
+	repeatable := map[string]bool{}
+	set.VisitAll(func(f *flagr.Flag) error {
+		repeatable[f.Name] = flagr.IsRepeatable(f)
+		return nil
+	})
+
+	want := map[string]bool{
+		"int":   false,
+		"ints":  true,
+		"url":   false,
+		"urls":  true,
+		"level": false,
+	}
+	if diff := cmp.Diff(want, repeatable); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIntRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []int
+		wantErr bool
+	}{
+		{name: "default", args: nil, want: []int{42}},
+		{name: "single values", args: []string{"-cpus", "1,3"}, want: []int{1, 3}},
+		{name: "negative values", args: []string{"-cpus", "-1,-3--2"}, want: []int{-1, -3, -2}},
+		{name: "ranges", args: []string{"-cpus", "0-2,4,6-7"}, want: []int{0, 1, 2, 4, 6, 7}},
+		{name: "accumulates", args: []string{"-cpus", "0-1", "-cpus", "3"}, want: []int{0, 1, 3}},
+		{name: "reversed range", args: []string{"-cpus", "2-0"}, wantErr: true},
+		{name: "not an int", args: []string{"-cpus", "a"}, wantErr: true},
+		{name: "not an int in range", args: []string{"-cpus", "0-a"}, wantErr: true},
+		{name: "empty token", args: []string{"-cpus", "1,,2"}, wantErr: true},
+		{name: "max int", args: []string{"-cpus", strconv.Itoa(math.MaxInt-1) + "-" + strconv.Itoa(math.MaxInt)}, want: []int{math.MaxInt - 1, math.MaxInt}},
+		{name: "min int", args: []string{"-cpus", strconv.Itoa(math.MinInt) + "-" + strconv.Itoa(math.MinInt+1)}, want: []int{math.MinInt, math.MinInt + 1}},
+		{name: "too large", args: []string{"-cpus", "0-99999999999"}, wantErr: true},
+		{name: "too large by one", args: []string{"-cpus", "0-65536"}, wantErr: true},
+		{name: "whole int range", args: []string{"-cpus", strconv.Itoa(math.MinInt) + "-" + strconv.Itoa(math.MaxInt)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			cpus := flagr.Add(set, "cpus", flagr.IntRanges(42), "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(*cpus, tt.want) {
+				t.Errorf("cpus = %v, want %v", *cpus, tt.want)
+			}
+		})
+	}
+
+	t.Run("too large error", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		flagr.Add(set, "cpus", flagr.IntRanges(), "")
+		err := set.Set("", "cpus", "1-65537")
+		if want := `invalid range "1-65537": expands to more than 65536 values`; err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+		if err := set.Set("", "cpus", "1-65536"); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+	})
+}
+
+func TestTryAdd(t *testing.T) {
+	var set flagr.Set
+	a, err := flagr.TryAdd(&set, "a", flagr.Int(1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1; *a != want {
+		t.Errorf("a = %v, want %v", *a, want)
+	}
+
+	b, err := flagr.TryAdd(&set, "a", flagr.String("b"), "")
+	if !errors.Is(err, flagr.ErrRedefined) {
+		t.Errorf("err = %v, want %v", err, flagr.ErrRedefined)
+	}
+	if b != nil {
+		t.Errorf("b = %v, want nil", b)
+	}
+
+	if err := set.Parse([]string{"-a", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := 2; *a != want {
+		t.Errorf("a = %v, want %v", *a, want)
+	}
+}
+
+func TestTryAddConcurrent(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		var set flagr.Set
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for j := range errs {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				_, errs[j] = flagr.TryAdd(&set, "a", flagr.Int(j), "")
+			}(j)
+		}
+		wg.Wait()
+
+		var redefined int
+		for _, err := range errs {
+			switch {
+			case errors.Is(err, flagr.ErrRedefined):
+				redefined++
+			case err != nil:
+				t.Fatal(err)
+			}
+		}
+		if want := len(errs) - 1; redefined != want {
+			t.Fatalf("got %d ErrRedefined, want %d", redefined, want)
+		}
+	}
+}
+
+func TestIOArg(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want flagr.IOTarget
+	}{
+		{name: "default", args: nil, want: flagr.IOTarget{Path: "-", IsStd: true}},
+		{name: "std", args: []string{"-in", "-"}, want: flagr.IOTarget{Path: "-", IsStd: true}},
+		{name: "path", args: []string{"-in", "a/b.txt"}, want: flagr.IOTarget{Path: "a/b.txt", IsStd: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set flagr.Set
+			in := flagr.Add(&set, "in", flagr.IOArg("-"), "")
+			if err := set.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *in != tt.want {
+				t.Errorf("in = %+v, want %+v", *in, tt.want)
+			}
+			if want, got := tt.want.Path, set.Lookup("in").Value.String(); got != want {
+				t.Errorf("String() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMAC(t *testing.T) {
+	t.Run("parses mac addresses", func(t *testing.T) {
+		var set flagr.Set
+		mac := flagr.Add(&set, "mac", flagr.MAC(nil), "")
+		if err := set.Parse([]string{"-mac", "aa:bb:cc:dd:ee:ff"}); err != nil {
+			t.Fatal(err)
+		}
+		if want := testflags.MustMAC("aa:bb:cc:dd:ee:ff"); !reflect.DeepEqual(*mac, want) {
+			t.Errorf("mac = %v, want %v", *mac, want)
+		}
+		if want, got := "aa:bb:cc:dd:ee:ff", set.Lookup("mac").Value.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("prints nil as empty", func(t *testing.T) {
+		var set flagr.Set
+		flagr.Add(&set, "mac", flagr.MAC(nil), "")
+		if want, got := "", set.Lookup("mac").Value.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("fails on invalid mac addresses", func(t *testing.T) {
+		var set flagr.Set
+		flagr.Add(&set, "mac", flagr.MAC(nil), "")
+		err := set.Set("", "mac", "aa:bb:cc")
+		if want := (&net.AddrError{}); !errors.As(err, &want) {
+			t.Fatalf("err = %v, want %T", err, want)
+		}
+	})
+}
+
+func TestFprintValues(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "a", flagr.String("a"), "")
+	flagr.Add(&set, "bb", flagr.String("héllo wörld"), "")
+	flagr.Add(&set, "c", flagr.Strings("a", "b", "c"), "")
+	if err := set.Parse([]string{"-a", "ação"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	set.FprintValues(&output)
+	want := `Current configuration:
+  -a ação         (flags)
+  -bb héllo wörld (default)
+  -c [a, b, c]    (default)
+`
+	if diff := cmp.Diff(want, output.String()); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBoolOrDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       flagr.Toggle
+		wantString string
+		wantErr    bool
+	}{
+		{name: "default", args: nil, want: flagr.Toggle{}, wantString: "false"},
+		{name: "bare", args: []string{"-cache"}, want: flagr.Toggle{Enabled: true}, wantString: "true"},
+		{name: "bool", args: []string{"-cache=false"}, want: flagr.Toggle{}, wantString: "false"},
+		{name: "duration", args: []string{"-cache=5m"}, want: flagr.Toggle{Enabled: true, TTL: 5 * time.Minute}, wantString: "5m0s"},
+		{name: "negative duration", args: []string{"-cache=-5m"}, wantErr: true},
+		{name: "invalid", args: []string{"-cache=maybe"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			cache := flagr.Add(set, "cache", flagr.BoolOrDuration(flagr.Toggle{}), "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *cache != tt.want {
+				t.Errorf("cache = %+v, want %+v", *cache, tt.want)
+			}
+			if got := set.Lookup("cache").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestFeatureSet(t *testing.T) {
+	known := []string{"a", "b", "c"}
+	tests := []struct {
+		name         string
+		args         []string
+		want         map[string]bool
+		wantString   string
+		wantErr      bool
+		wantErrValue map[string]bool
+	}{
+		{name: "default", args: nil, want: map[string]bool{"a": false, "b": false, "c": false}, wantString: ""},
+		{name: "enables", args: []string{"-features", "c,+a"}, want: map[string]bool{"a": true, "b": false, "c": true}, wantString: "a,c"},
+		{name: "disables", args: []string{"-features", "a,b,-a"}, want: map[string]bool{"a": false, "b": true, "c": false}, wantString: "b"},
+		{name: "merges", args: []string{"-features", "a,b", "-features", "-b,c"}, want: map[string]bool{"a": true, "b": false, "c": true}, wantString: "a,c"},
+		{name: "unknown", args: []string{"-features", "a,d"}, wantErr: true},
+		{name: "unknown is not applied partially", args: []string{"-features", "b", "-features", "a,-b,d"}, wantErr: true, wantErrValue: map[string]bool{"a": false, "b": true, "c": false}},
+		{name: "unknown disabled", args: []string{"-features", "-d"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			features := flagr.Add(set, "features", flagr.FeatureSet(known), "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if diff := cmp.Diff(tt.wantErrValue, *features); tt.wantErrValue != nil && diff != "" {
+					t.Errorf("value changed on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.want, *features); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("features").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	t.Run("is reported as the default", func(t *testing.T) {
+		var set flagr.Set
+		a := flagr.Add(&set, "a", flagr.Int(1), "usage")
+		if err := set.SetDefault("a", "2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := 2; *a != want {
+			t.Errorf("a = %v, want %v", *a, want)
+		}
+		if want, got := "2", set.Lookup("a").DefValue; got != want {
+			t.Errorf("DefValue = %q, want %q", got, want)
+		}
+
+		var output bytes.Buffer
+		set.FprintValues(&output)
+		want := `Current configuration:
+  -a 2 (default)
+`
+		if diff := cmp.Diff(want, output.String()); diff != "" {
+			t.Errorf("values mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("can be overridden", func(t *testing.T) {
+		var set flagr.Set
+		a := flagr.Add(&set, "a", flagr.Int(1), "")
+		b := flagr.Add(&set, "b", flagr.Ints(1), "")
+		c := flagr.Add(&set, "c", flagr.Int(1), "")
+		for name, val := range map[string]string{"a": "2", "b": "2", "c": "2"} {
+			if err := set.SetDefault(name, val); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		setC := func(set *flagr.Set) error {
+			return set.VisitRemaining(func(f *flagr.Flag) error {
+				if f.Name == "c" {
+					return set.Set("parser", f.Name, "3")
+				}
+				return nil
+			})
+		}
+		if err := set.Parse([]string{"-a", "3", "-b", "3", "-b", "4"}, setC); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := 3; *a != want {
+			t.Errorf("a = %v, want %v", *a, want)
+		}
+		if want := []int{3, 4}; !reflect.DeepEqual(*b, want) {
+			t.Errorf("b = %v, want %v", *b, want)
+		}
+		if want := 3; *c != want {
+			t.Errorf("c = %v, want %v", *c, want)
+		}
+	})
+
+	t.Run("fails on unknown flags", func(t *testing.T) {
+		var set flagr.Set
+		if err := set.SetDefault("a", "1"); err == nil {
+			t.Fatal("err is nil")
+		}
+	})
+
+	t.Run("fails on invalid values", func(t *testing.T) {
+		var set flagr.Set
+		flagr.Add(&set, "a", flagr.Int(1), "")
+		if err := set.SetDefault("a", "not a number"); !errors.As(err, new(*strconv.NumError)) {
+			t.Fatalf("err = %v, want a *strconv.NumError", err)
+		}
+	})
+}
+
+func TestISODuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "PT1H30M", want: 90 * time.Minute},
+		{in: "P1DT12H", want: 36 * time.Hour},
+		{in: "P1W", want: 7 * 24 * time.Hour},
+		{in: "PT1.5S", want: 1500 * time.Millisecond},
+		{in: "PT0S", want: 0},
+		{in: "-PT1M", want: -time.Minute},
+		{in: "P1Y", wantErr: true},
+		{in: "P1M", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "PT", wantErr: true},
+		{in: "P1DT", wantErr: true},
+		{in: "PT1M1H", wantErr: true},
+		{in: "P1H", wantErr: true},
+		{in: "PT1D", wantErr: true},
+		{in: "1H", wantErr: true},
+		{in: "PT1", wantErr: true},
+		{in: "P1000000W", wantErr: true},
+		{in: "PT9223372036.854775808S", wantErr: true}, // exactly 1<<63 ns
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var set flagr.Set
+			d := flagr.Add(&set, "d", flagr.ISODuration(0), "")
+			err := set.Set("", "d", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *d != tt.want {
+				t.Errorf("d = %v, want %v", *d, tt.want)
+			}
+		})
+	}
+
+	t.Run("accumulates", func(t *testing.T) {
+		var set flagr.Set
+		d := flagr.Add(&set, "d", flagr.ISODurations(time.Second), "")
+		if err := set.Parse([]string{"-d", "PT1M", "-d", "P1D"}); err != nil {
+			t.Fatal(err)
+		}
+		if want := []time.Duration{time.Minute, 24 * time.Hour}; !reflect.DeepEqual(*d, want) {
+			t.Errorf("d = %v, want %v", *d, want)
+		}
+	})
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       []string
+		wantString string
+	}{
+		{name: "default", args: nil, want: []string{"a b"}, wantString: `["a b"]`},
+		{name: "lf", args: []string{"-l", "a\nb c\n"}, want: []string{"a", "b c"}, wantString: `["a", "b c"]`},
+		{name: "crlf", args: []string{"-l", "a\r\nb c\r\n"}, want: []string{"a", "b c"}, wantString: `["a", "b c"]`},
+		{name: "trailing blank lines", args: []string{"-l", "a\n\nb\n\n\r\n"}, want: []string{"a", "", "b"}, wantString: `["a", "", "b"]`},
+		{name: "accumulates", args: []string{"-l", "a\nb", "-l", "c"}, want: []string{"a", "b", "c"}, wantString: `["a", "b", "c"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set flagr.Set
+			l := flagr.Add(&set, "l", flagr.Lines("a b"), "")
+			if err := set.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*l, tt.want) {
+				t.Errorf("l = %q, want %q", *l, tt.want)
+			}
+			if got := set.Lookup("l").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %s, want %s", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "a", flagr.Int(0), "")
+	flagr.Add(&set, "b", flagr.Int(0), "")
+
+	set.Annotate("a", "category", "network")
+	set.Annotate("a", "since", 2)
+	set.Annotate("a", "since", 3)
+
+	tests := []struct {
+		name, key string
+		want      any
+		wantOk    bool
+	}{
+		{name: "a", key: "category", want: "network", wantOk: true},
+		{name: "a", key: "since", want: 3, wantOk: true},
+		{name: "a", key: "experimental", want: nil, wantOk: false},
+		{name: "b", key: "category", want: nil, wantOk: false},
+		{name: "c", key: "category", want: nil, wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := set.Annotation(tt.name, tt.key)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("Annotation(%q, %q) = (%v, %v), want (%v, %v)", tt.name, tt.key, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestPrefixSet(t *testing.T) {
+	defaults := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name    string
+		opts    []flagr.PrefixSetOption
+		args    []string
+		want    []netip.Prefix
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: defaults,
+		},
+		{
+			name: "accumulates overlapping prefixes",
+			args: []string{"-p", "10.0.0.0/8", "-p", "10.1.0.0/16"},
+			want: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")},
+		},
+		{
+			name: "accepts disjoint prefixes",
+			opts: []flagr.PrefixSetOption{flagr.DisallowOverlap()},
+			args: []string{"-p", "10.0.0.0/16", "-p", "10.1.0.0/16", "-p", "::1/128"},
+			want: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/16"), netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("::1/128")},
+		},
+		{
+			name:    "rejects overlapping prefixes",
+			opts:    []flagr.PrefixSetOption{flagr.DisallowOverlap()},
+			args:    []string{"-p", "10.0.0.0/8", "-p", "10.1.0.0/16"},
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid prefixes",
+			args:    []string{"-p", "10.0.0.0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			p := flagr.Add(set, "p", flagr.PrefixSet(defaults, tt.opts...), "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *p, cmp.Comparer(func(a, b netip.Prefix) bool { return a == b })); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("prints the prefixes", func(t *testing.T) {
+		var set flagr.Set
+		flagr.Add(&set, "p", flagr.PrefixSet(nil), "")
+		if err := set.Parse([]string{"-p", "10.0.0.0/8", "-p", "::/0"}); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := "[10.0.0.0/8, ::/0]", set.Lookup("p").Value.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestPrintDefaultsVerbose(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "addr", flagr.String(""), "the `address` to listen on")
+	flagr.Add(&set, "name", flagr.String("a"), "the name")
+	flagr.Add(&set, "v", flagr.Bool(false), "verbose")
+	set.SetExample("addr", "0.0.0.0:8080")
+	set.SetExample("v", "true")
+
+	var defaults, verbose bytes.Buffer
+	set.SetOutput(&defaults)
+	set.PrintDefaults()
+	set.SetOutput(&verbose)
+	set.PrintDefaultsVerbose()
+
+	// the example goes after the usage line of each flag
+	lines := strings.SplitAfter(defaults.String(), "\n")
+	var want string
+	for _, line := range lines {
+		want += line
+		switch {
+		case strings.Contains(line, "the address to listen on"):
+			want += "    \t(example: 0.0.0.0:8080)\n"
+		case strings.Contains(line, "verbose"):
+			want += "    \t(example: true)\n"
+		}
+	}
+	if diff := cmp.Diff(want, verbose.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want, got := 2, strings.Count(verbose.String(), "(example:"); got != want {
+		t.Errorf("examples = %d, want %d", got, want)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		getter     flagr.Getter[[]flagr.KeyValue]
+		args       []string
+		want       []flagr.KeyValue
+		wantString string
+		wantErr    bool
+	}{
+		{
+			name:       "default",
+			getter:     flagr.KeyValues("A=1"),
+			want:       []flagr.KeyValue{{"A", "1"}},
+			wantString: "[A=1]",
+		},
+		{
+			name:       "preserves order and duplicates",
+			getter:     flagr.KeyValues("A=1"),
+			args:       []string{"-e", "B=2", "-e", "A=1", "-e", "B=3=4"},
+			want:       []flagr.KeyValue{{"B", "2"}, {"A", "1"}, {"B", "3=4"}},
+			wantString: "[B=2, A=1, B=3=4]",
+		},
+		{
+			name:       "missing separator",
+			getter:     flagr.KeyValues(),
+			args:       []string{"-e", "A"},
+			want:       []flagr.KeyValue{{"A", ""}},
+			wantString: "[A=]",
+		},
+		{
+			name:    "strict missing separator",
+			getter:  flagr.StrictKeyValues(),
+			args:    []string{"-e", "A"},
+			wantErr: true,
+		},
+		{
+			name:       "strict",
+			getter:     flagr.StrictKeyValues(),
+			args:       []string{"-e", "A="},
+			want:       []flagr.KeyValue{{"A", ""}},
+			wantString: "[A=]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			e := flagr.Add(set, "e", tt.getter, "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *e); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("e").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestStringValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		getter  flagr.Getter[string]
+		value   string
+		wantErr string
+	}{
+		{name: "non empty ok", getter: flagr.StringValidated("", flagr.NonEmpty()), value: "a"},
+		{name: "non empty", getter: flagr.StringValidated("", flagr.NonEmpty()), value: "", wantErr: "invalid value, must not be empty"},
+		{name: "min len ok", getter: flagr.StringValidated("", flagr.MinLen(2)), value: "ab"},
+		{name: "min len", getter: flagr.StringValidated("", flagr.MinLen(2)), value: "a", wantErr: `invalid value "a", must be at least 2 characters long`},
+		{name: "max len ok", getter: flagr.StringValidated("", flagr.MaxLen(2)), value: "éé"},
+		{name: "max len", getter: flagr.StringValidated("", flagr.MaxLen(2)), value: "abc", wantErr: `invalid value "abc", must be at most 2 characters long`},
+		{name: "match ok", getter: flagr.StringValidated("", flagr.Match(regexp.MustCompile(`^[a-z]+$`))), value: "abc"},
+		{name: "match", getter: flagr.StringValidated("", flagr.Match(regexp.MustCompile(`^[a-z]+$`))), value: "ABC", wantErr: `invalid value "ABC", must match ^[a-z]+$`},
+		{name: "all", getter: flagr.StringValidated("", flagr.NonEmpty(), flagr.MaxLen(3)), value: "abcd", wantErr: `invalid value "abcd", must be at most 3 characters long`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "s", tt.getter, "")
+
+			err := set.Set("", "s", tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Set() err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set() err = %v, want nil", err)
+			}
+			if *v != tt.value {
+				t.Errorf("got %q, want %q", *v, tt.value)
+			}
+		})
+	}
+}
+
+func TestStringsValidated(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "s", flagr.StringsValidated([]string{""}, flagr.NonEmpty()), "")
+
+	if diff := cmp.Diff([]string{""}, *v); diff != "" {
+		t.Errorf("default mismatch (-want +got):\n%s", diff)
+	}
+	if err := set.Parse([]string{"-s", "a", "-s", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, *v); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if err := set.Set("", "s", ""); err == nil {
+		t.Errorf("Set() err = nil, want error")
+	}
+}
+
+func TestMapValue(t *testing.T) {
+	half := func(i int) (int, error) {
+		if i%2 != 0 {
+			return 0, errors.New("odd")
+		}
+		return i / 2, nil
+	}
+
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "v", flagr.MapValue(flagr.Int(4), half), "")
+	sum := flagr.Add(set, "sum", flagr.MapValue(flagr.Ints(), func(s []int) (int, error) {
+		var sum int
+		for _, i := range s {
+			sum += i
+		}
+		return sum, nil
+	}), "")
+
+	if *v != 2 {
+		t.Errorf("default = %d, want 2", *v)
+	}
+	if err := set.Set("", "v", "x"); err == nil {
+		t.Errorf("inner err = nil, want error")
+	}
+	if err := set.Set("", "v", "3"); err == nil || err.Error() != "odd" {
+		t.Errorf("transform err = %v, want odd", err)
+	}
+	if err := set.Parse([]string{"-v", "10", "-sum", "1", "-sum", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if *v != 5 {
+		t.Errorf("v = %d, want 5", *v)
+	}
+	if *sum != 3 {
+		t.Errorf("sum = %d, want 3", *sum)
+	}
+	if !flagr.IsRepeatable(set.Lookup("sum")) {
+		t.Errorf("sum is not repeatable")
+	}
+}
+
+func TestSemVer(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    flagr.Version
+		wantErr bool
+	}{
+		{in: "1.2.3", want: flagr.Version{Major: 1, Minor: 2, Patch: 3}},
+		{in: "0.0.0", want: flagr.Version{}},
+		{in: "1.0.0-alpha.1", want: flagr.Version{Major: 1, Pre: "alpha.1"}},
+		{in: "1.0.0+build.5", want: flagr.Version{Major: 1, Build: "build.5"}},
+		{in: "1.0.0-rc-1+20230101.sha-abc", want: flagr.Version{Major: 1, Pre: "rc-1", Build: "20230101.sha-abc"}},
+		{in: "1.0.0+001", want: flagr.Version{Major: 1, Build: "001"}},
+		{in: "1.2", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "v1.2.3", wantErr: true},
+		{in: "01.2.3", wantErr: true},
+		{in: "1.2.x", wantErr: true},
+		{in: "1.2.3-", wantErr: true},
+		{in: "1.2.3-01", wantErr: true},
+		{in: "1.2.3-a..b", wantErr: true},
+		{in: "1.2.3+", wantErr: true},
+		{in: "1.2.3+a_b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "v", flagr.SemVer("0.0.1"), "")
+
+			err := set.Set("", "v", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("v").Value.String(); got != tt.in {
+				t.Errorf("String() = %q, want %q", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestSemVers(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "v", flagr.SemVers("1.0.0"), "")
+	if err := set.Parse([]string{"-v", "1.2.3", "-v", "2.0.0-rc.1"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []flagr.Version{{Major: 1, Minor: 2, Patch: 3}, {Major: 2, Pre: "rc.1"}}
+	if diff := cmp.Diff(want, *v); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// in ascending order, as listed in the spec
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	var versions []flagr.Version
+	for _, s := range ordered {
+		versions = append(versions, *flagr.SemVer(s).Val())
+	}
+
+	for i := range versions {
+		for j := range versions {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := versions[i].Compare(versions[j]); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", versions[i], versions[j], got, want)
+			}
+		}
+	}
+
+	a, b := *flagr.SemVer("1.0.0+a").Val(), *flagr.SemVer("1.0.0+b").Val()
+	if got := a.Compare(b); got != 0 {
+		t.Errorf("build metadata should be ignored, got %d", got)
+	}
+}
+
+func TestWriteINI(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "addr", flagr.String("localhost"), "listen\naddress")
+	flagr.Add(set, "debug", flagr.Bool(false), "")
+	flagr.Add(set, "port", flagr.Int(80), "listen port")
+	flagr.Add(set, "ratio", flagr.Float64(0.5), "")
+	flagr.Add(set, "timeout", flagr.Duration(time.Second), "")
+	flagr.Add(set, "tags", flagr.Strings("a"), "tags")
+	flagr.Add(set, "ports", flagr.Ints(), "")
+	flagr.Add(set, "http.addr", flagr.String("a\tb"), "")
+	flagr.Add(set, "limit", flagr.Float64(math.Inf(1)), "")
+	flagr.Add(set, "scale", flagr.Float64(2), "")
+	if err := set.Parse([]string{"-port", "8080", "-tags", "x", "-tags", `y"z`}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := set.WriteINI(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		`addr        = "localhost"   # listen address (default)`,
+		`debug       = false         # (default)`,
+		`"http.addr" = "a\tb"        # (default)`,
+		`limit       = inf           # (default)`,
+		`port        = 8080          # listen port (flags)`,
+		`ports       = []            # (default)`,
+		`ratio       = 0.5           # (default)`,
+		`scale       = 2.0           # (default)`,
+		`tags        = ["x", "y\"z"] # tags (flags)`,
+		`timeout     = "1s"          # (default)`,
+		``,
+	}, "\n")
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTimeOfDay(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       flagr.Clock
+		wantString string
+		wantErr    bool
+	}{
+		{in: "15:04", want: flagr.Clock{Hour: 15, Minute: 4}, wantString: "15:04"},
+		{in: "15:04:05", want: flagr.Clock{Hour: 15, Minute: 4, Second: 5}, wantString: "15:04:05"},
+		{in: "9:30", want: flagr.Clock{Hour: 9, Minute: 30}, wantString: "09:30"},
+		{in: "00:00:00", want: flagr.Clock{}, wantString: "00:00"},
+		{in: "23:59:59", want: flagr.Clock{Hour: 23, Minute: 59, Second: 59}, wantString: "23:59:59"},
+		{in: "25:00", wantErr: true},
+		{in: "24:00", wantErr: true},
+		{in: "12:60", wantErr: true},
+		{in: "12:00:60", wantErr: true},
+		{in: "12", wantErr: true},
+		{in: "12:0", wantErr: true},
+		{in: "12:00:00:00", wantErr: true},
+		{in: "-1:00", wantErr: true},
+		{in: "ab:cd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "t", flagr.TimeOfDay("12:00"), "")
+
+			err := set.Set("", "t", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("t").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestTimesOfDay(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "t", flagr.TimesOfDay("12:00"), "")
+	if err := set.Parse([]string{"-t", "08:00", "-t", "20:30:15"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []flagr.Clock{{Hour: 8}, {Hour: 20, Minute: 30, Second: 15}}
+	if diff := cmp.Diff(want, *v); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := set.Lookup("t").Value.String(), "[08:00, 20:30:15]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "v", flagr.Int(1), "")
+	if err := set.Parse([]string{"-v", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	set.Freeze()
+
+	if err := set.Set("", "v", "3"); !errors.Is(err, flagr.ErrFrozen) {
+		t.Errorf("Set() err = %v, want %v", err, flagr.ErrFrozen)
+	}
+	if err := set.SetDefault("v", "3"); !errors.Is(err, flagr.ErrFrozen) {
+		t.Errorf("SetDefault() err = %v, want %v", err, flagr.ErrFrozen)
+	}
+	if err := set.Parse([]string{"-v", "3"}); !errors.Is(err, flagr.ErrFrozen) {
+		t.Errorf("Parse() err = %v, want %v", err, flagr.ErrFrozen)
+	}
+	if _, err := flagr.TryAdd(set, "w", flagr.Int(1), ""); !errors.Is(err, flagr.ErrFrozen) {
+		t.Errorf("TryAdd() err = %v, want %v", err, flagr.ErrFrozen)
+	}
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, flagr.ErrFrozen) {
+				t.Errorf("Add() panic = %v, want %v", err, flagr.ErrFrozen)
+			}
+		}()
+		flagr.Add(set, "w", flagr.Int(1), "")
+	}()
+
+	if *v != 2 {
+		t.Errorf("v = %d, want 2", *v)
+	}
+	if set.Lookup("v") == nil || set.Lookup("w") != nil {
+		t.Errorf("Lookup() returned unexpected results")
+	}
+	var buf bytes.Buffer
+	set.FprintValues(&buf)
+	if want := "Current configuration:\n  -v 2 (flags)\n"; buf.String() != want {
+		t.Errorf("FprintValues() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFreezePanics(t *testing.T) {
+	set := flagr.NewSet("", flagr.PanicOnError)
+	flagr.Add(set, "v", flagr.Int(1), "")
+	set.Freeze()
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, flagr.ErrFrozen) {
+			t.Errorf("Set() panic = %v, want %v", err, flagr.ErrFrozen)
+		}
+	}()
+	set.Set("", "v", "2")
+}
+
+func TestEnumSet(t *testing.T) {
+	allowed := []string{"json", "yaml", "toml"}
+	tests := []struct {
+		name       string
+		getter     flagr.Getter[[]string]
+		args       []string
+		want       []string
+		wantString string
+		wantErr    bool
+	}{
+		{
+			name:       "default",
+			getter:     flagr.EnumSet(",", allowed, "json"),
+			want:       []string{"json"},
+			wantString: "json",
+		},
+		{
+			name:       "valid",
+			getter:     flagr.EnumSet(",", allowed, "json"),
+			args:       []string{"-f", "yaml, toml", "-f", "json"},
+			want:       []string{"yaml", "toml", "json"},
+			wantString: "yaml,toml,json",
+		},
+		{
+			name:       "duplicates",
+			getter:     flagr.EnumSet("|", allowed),
+			args:       []string{"-f", "yaml|yaml|json", "-f", "yaml"},
+			want:       []string{"yaml", "yaml", "json", "yaml"},
+			wantString: "yaml|yaml|json|yaml",
+		},
+		{
+			name:       "dedupe",
+			getter:     flagr.EnumSetDedupe("|", allowed, "json", "json"),
+			args:       []string{"-f", "yaml|yaml|json", "-f", "yaml"},
+			want:       []string{"yaml", "json"},
+			wantString: "yaml|json",
+		},
+		{
+			name:       "dedupe defaults",
+			getter:     flagr.EnumSetDedupe("|", allowed, "json|yaml", "json"),
+			want:       []string{"json", "yaml"},
+			wantString: "json|yaml",
+		},
+		{
+			name:    "invalid",
+			getter:  flagr.EnumSet(",", allowed),
+			args:    []string{"-f", "json,xml"},
+			wantErr: true,
+		},
+		{
+			name:    "case sensitive",
+			getter:  flagr.EnumSet(",", allowed),
+			args:    []string{"-f", "JSON"},
+			wantErr: true,
+		},
+		{
+			name:       "fold",
+			getter:     flagr.EnumSetFold(",", allowed),
+			args:       []string{"-f", "JSON,Yaml,json"},
+			want:       []string{"json", "yaml", "json"},
+			wantString: "json,yaml,json",
+		},
+		{
+			name:       "fold dedupe",
+			getter:     flagr.EnumSetFoldDedupe(",", allowed),
+			args:       []string{"-f", "JSON,Yaml,json"},
+			want:       []string{"json", "yaml"},
+			wantString: "json,yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "f", tt.getter, "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("f").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+
+	t.Run("invalid error", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		flagr.Add(set, "f", flagr.EnumSet(",", allowed), "")
+		err := set.Set("", "f", "json,xml")
+		if want := `invalid value "xml", must be one of: json, yaml, toml`; err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	})
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	// non bool flags always consume the next argument, so negative numbers
+	// don't need "=" to be recognized as values
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	threshold := flagr.Add(set, "threshold", flagr.Int(0), "")
+	n := flagr.Add(set, "n", flagr.Int(0), "")
+	m := flagr.Add(set, "m", flagr.Float64s(), "")
+
+	if err := set.Parse([]string{"-threshold", "-5", "-n", "-1", "-m", "-2", "--m", "-2.5e3", "x", "-n", "-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if *threshold != -5 {
+		t.Errorf("threshold = %d, want -5", *threshold)
+	}
+	if *n != -1 {
+		t.Errorf("n = %d, want -1", *n)
+	}
+	if diff := cmp.Diff([]float64{-2, -2500}, *m); diff != "" {
+		t.Errorf("m mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"x", "-n", "-3"}, set.Args()); diff != "" {
+		t.Errorf("args mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStringOrFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"key.pem":     &fstest.MapFile{Data: []byte("  secret\n")},
+		"dir/tok.txt": &fstest.MapFile{Data: []byte("token")},
+	}
+	tests := []struct {
+		name       string
+		value      string
+		want       string
+		wantString string
+		wantErr    string
+	}{
+		{name: "literal", value: "abc", want: "abc", wantString: "abc"},
+		{name: "file", value: "@./key.pem", want: "secret", wantString: "@./key.pem"},
+		{name: "nested file", value: "@dir/tok.txt", want: "token", wantString: "@dir/tok.txt"},
+		{name: "missing file", value: "@nope.pem", wantErr: `unable to read "nope.pem": open nope.pem: file does not exist`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "key", flagr.StringOrFile("@default", flagr.FromFS(fsys)), "")
+			if *v != "@default" {
+				t.Errorf("default = %q, want %q", *v, "@default")
+			}
+
+			err := set.Set("", "key", tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("err = %v, want %v", err, fs.ErrNotExist)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tt.want {
+				t.Errorf("got %q, want %q", *v, tt.want)
+			}
+			if got := set.Lookup("key").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       float64
+		wantString string
+		wantErr    bool
+	}{
+		{in: "10MB/s", want: 10e6, wantString: "10MB/s"},
+		{in: "500KiB/s", want: 500 * 1024, wantString: "512kB/s"},
+		{in: "1.5gb/s", want: 1.5e9, wantString: "1.5GB/s"},
+		{in: "60kB/m", want: 1e3, wantString: "1kB/s"},
+		{in: "3600MiB/h", want: 1 << 20, wantString: "1.048576MB/s"},
+		{in: "100/s", want: 100, wantString: "100B/s"},
+		{in: "0B/s", want: 0, wantString: "0B/s"},
+		{in: "10MB", wantErr: true},
+		{in: "10MB/d", wantErr: true},
+		{in: "10XB/s", wantErr: true},
+		{in: "-1MB/s", wantErr: true},
+		{in: "MB/s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "r", flagr.Rate(1), "")
+
+			err := set.Set("", "r", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *v != tt.want {
+				t.Errorf("got %v, want %v", *v, tt.want)
+			}
+			if got := set.Lookup("r").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "2.5", want: 2500 * time.Millisecond},
+		{in: "1500ms", want: 1500 * time.Millisecond},
+		{in: "2s500ms", want: 2500 * time.Millisecond},
+		{in: "0", want: 0},
+		{in: "-1", want: -time.Second},
+		{in: "1e-3", want: time.Millisecond},
+		{in: "abc", wantErr: true},
+		{in: "1x", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "1e20", wantErr: true},
+		{in: "9223372036.854775807", wantErr: true}, // rounds to exactly 1<<63 ns
+		{in: "-9223372036.854775808", want: math.MinInt64},
+		{in: "9223372036", want: 9223372036 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "d", flagr.Seconds(time.Second), "")
+
+			err := set.Set("", "d", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *v != tt.want {
+				t.Errorf("got %v, want %v", *v, tt.want)
+			}
+			if got := set.Lookup("d").Value.String(); got != tt.want.String() {
+				t.Errorf("String() = %q, want %q", got, tt.want.String())
+			}
+		})
+	}
+
+	t.Run("list", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		v := flagr.Add(set, "d", flagr.SecondsList(time.Second), "")
+		if err := set.Parse([]string{"-d", "0.5", "-d", "1m"}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]time.Duration{500 * time.Millisecond, time.Minute}, *v); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "n", flagr.Int(0), "")
+	flagr.Add(set, "s", flagr.Strings(), "")
+	if err := set.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := set.Set("test", "n", strconv.Itoa(i*j)); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := set.Set("test", "s", "x"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				set.FprintValues(ioutil.Discard)
+				set.Provenance()
+				set.GetString("s")
+				if _, ok := flagr.Get[int](set, "n"); !ok {
+					t.Error("Get() = false, want true")
+					return
+				}
+				if s, _ := flagr.Get[[]string](set, "s"); len(s) > 0 {
+					_ = s[len(s)-1]
+				}
+				set.VisitAll(func(f *flagr.Flag) error { return nil })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, ok := flagr.Get[[]string](set, "s"); !ok || len(got) != 400 {
+		t.Errorf("Get() = %d values, %v, want 400, true", len(got), ok)
+	}
+	if _, ok := flagr.Get[string](set, "n"); ok {
+		t.Errorf("Get() with the wrong type = true, want false")
+	}
+	if _, ok := set.GetString("nope"); ok {
+		t.Errorf("GetString() of unknown flag = true, want false")
+	}
+}
+
+func TestConcurrentUsage(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "n", flagr.Int(0), "")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			set.SetTypeNamer(flagr.GoTypeName)
+			set.SetExample("n", strconv.Itoa(j))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			set.PrintDefaults()
+			set.PrintDefaultsVerbose()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestUsageUsesSet(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "n", flagr.Int(0), "")
+
+	var visited []string
+	set.SetUsage(func() {
+		set.VisitAll(func(f *flagr.Flag) error {
+			visited = append(visited, f.Name)
+			return nil
+		})
+		set.PrintDefaults()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- set.Parse([]string{"-h"}) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, flagr.ErrHelp) {
+			t.Errorf("Parse() error = %v, want %v", err, flagr.ErrHelp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Parse() did not return, usage deadlocked on the Set")
+	}
+	if diff := cmp.Diff([]string{"n"}, visited); diff != "" {
+		t.Errorf("visited flags mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetCopiesSlices(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "s", flagr.Strings("a", "b"), "")
+
+	got, _ := flagr.Get[[]string](set, "s")
+	if err := set.Set("", "s", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, got); diff != "" {
+		t.Errorf("value returned by Get changed (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		name         string
+		getter       flagr.Getter[[]flagr.Column]
+		args         []string
+		want         []flagr.Column
+		wantString   string
+		wantErr      string
+		wantErrValue []flagr.Column
+	}{
+		{
+			name:       "default",
+			getter:     flagr.Schema([]string{"id=int"}),
+			want:       []flagr.Column{{"id", "int"}},
+			wantString: "id=int",
+		},
+		{
+			name:       "preserves order",
+			getter:     flagr.Schema([]string{"id=int"}),
+			args:       []string{"-c", "name=string, id=int", "-c", "at=time"},
+			want:       []flagr.Column{{"name", "string"}, {"id", "int"}, {"at", "time"}},
+			wantString: "name=string,id=int,at=time",
+		},
+		{
+			name:       "duplicates allowed",
+			getter:     flagr.Schema(nil),
+			args:       []string{"-c", "id=int", "-c", "id=string"},
+			want:       []flagr.Column{{"id", "int"}, {"id", "string"}},
+			wantString: "id=int,id=string",
+		},
+		{
+			name:         "duplicates",
+			getter:       flagr.Schema(nil, flagr.UniqueNames()),
+			args:         []string{"-c", "id=int", "-c", "id=string"},
+			wantErr:      `duplicate column "id"`,
+			wantErrValue: []flagr.Column{{"id", "int"}},
+		},
+		{
+			name:    "unknown type",
+			getter:  flagr.Schema(nil, flagr.AllowedTypes("int", "string")),
+			args:    []string{"-c", "id=int,at=time"},
+			wantErr: `invalid type "time" for column "at", must be one of: int, string`,
+		},
+		{
+			name:    "malformed",
+			getter:  flagr.Schema(nil),
+			args:    []string{"-c", "id"},
+			wantErr: `invalid column "id", must be in the form name=type`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "c", tt.getter, "")
+
+			var err error
+			for i := 1; i < len(tt.args) && err == nil; i += 2 {
+				err = set.Set("", "c", tt.args[i])
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if diff := cmp.Diff(tt.wantErrValue, *v); tt.wantErrValue != nil && diff != "" {
+					t.Errorf("value changed on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("c").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	flagr.Add(set, "addr", flagr.String("localhost"), "listen `address`")
+	flagr.Add(set, "debug", flagr.Bool(false), "")
+	flagr.Add(set, "formats", flagr.EnumSet(",", []string{"json", "yaml"}, "json"), "output formats")
+	flagr.Add(set, "level", flagr.Level(1, []string{"debug", "info"}), "log level")
+	flagr.Add(set, "ports", flagr.Ints(80, 443), "")
+	flagr.Add(set, "ratio", flagr.Float64(0.5), "")
+	flagr.Add(set, "timeout", flagr.Duration(time.Second), "")
+	flagr.Add(set, "workers", flagr.Int(4), "")
+	set.MarkRequired("addr")
+
+	var buf bytes.Buffer
+	if err := set.JSONSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "addr": {
+    "type": "string",
+    "default": "localhost",
+    "usage": "listen address",
+    "required": true
+  },
+  "debug": {
+    "type": "boolean",
+    "default": false
+  },
+  "formats": {
+    "type": "array",
+    "items": {
+      "type": "string",
+      "enum": [
+        "json",
+        "yaml"
+      ]
+    },
+    "default": "json",
+    "usage": "output formats"
+  },
+  "level": {
+    "type": "string",
+    "enum": [
+      "debug",
+      "info"
+    ],
+    "default": "info",
+    "usage": "log level"
+  },
+  "ports": {
+    "type": "array",
+    "items": {
+      "type": "integer"
+    },
+    "default": "[80, 443]"
+  },
+  "ratio": {
+    "type": "number",
+    "default": 0.5
+  },
+  "timeout": {
+    "type": "string",
+    "default": "1s"
+  },
+  "workers": {
+    "type": "integer",
+    "default": 4
+  }
+}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDynamicEnum(t *testing.T) {
+	regions := []string{"eu-west-1", "us-east-1"}
+	calls := 0
+	allowed := func() []string {
+		calls++
+		return regions
+	}
+
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	region := flagr.Add(set, "region", flagr.DynamicEnum("", allowed), "")
+
+	if err := set.Parse([]string{"-region", "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "us-east-1"; *region != want {
+		t.Errorf("region = %q, want %q", *region, want)
+	}
+	if calls != 1 {
+		t.Errorf("allowed called %d times, want 1", calls)
+	}
+
+	regions = []string{"eu-west-1", "ap-south-1"}
+	err := set.Parse([]string{"-region", "us-east-1"})
+	if want := `invalid value "us-east-1" for flag -region: invalid value "us-east-1", must be one of: eu-west-1, ap-south-1`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+	if err := set.Parse([]string{"-region", "ap-south-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ap-south-1"; *region != want {
+		t.Errorf("region = %q, want %q", *region, want)
+	}
+}
+
+func TestSI(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       float64
+		wantString string
+		wantErr    bool
+	}{
+		{in: "1k", want: 1000, wantString: "1k"},
+		{in: "2.5M", want: 2.5e6, wantString: "2.5M"},
+		{in: "1G", want: 1e9, wantString: "1G"},
+		{in: "0.5k", want: 500, wantString: "500"},
+		{in: "-1.5k", want: -1500, wantString: "-1.5k"},
+		{in: "3E", want: 3e18, wantString: "3E"},
+		{in: "1500000000000", want: 1.5e12, wantString: "1.5T"},
+		{in: "42", want: 42, wantString: "42"},
+		{in: "1e3k", want: 1e6, wantString: "1M"},
+		{in: "1K", wantErr: true},
+		{in: "k", wantErr: true},
+		{in: "1kk", wantErr: true},
+		{in: "NaN", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "n", flagr.SI(0), "")
+
+			err := set.Set("", "n", tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *v != tt.want {
+				t.Errorf("got %v, want %v", *v, tt.want)
+			}
+			if got := set.Lookup("n").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestParseHook(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	flagr.Add(set, "a", flagr.String(""), "")
+	flagr.Add(set, "b", flagr.String(""), "")
+	flagr.Add(set, "c", flagr.String(""), "")
+	flagr.Add(set, "d", flagr.String(""), "")
+
+	setter := func(name string) flagr.Parser {
+		return func(set *flagr.Set) error {
+			return set.Set("test", name, "x")
+		}
+	}
+
+	type call struct {
+		stage, remaining int
+	}
+	var calls []call
+	set.SetParseHook(func(stage int, parser flagr.Parser, remaining int) {
+		calls = append(calls, call{stage, remaining})
+	})
+
+	if err := set.Parse([]string{"-a", "x"}, setter("b"), setter("c"), setter("d")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []call{{0, 3}, {1, 2}, {2, 1}}
+	if diff := cmp.Diff(want, calls, cmp.AllowUnexported(call{})); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPortRanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       []flagr.PortRange
+		wantString string
+		wantErr    bool
+	}{
+		{name: "default", want: []flagr.PortRange{{80, 80}}, wantString: "[80]"},
+		{name: "single", args: []string{"-p", "8080"}, want: []flagr.PortRange{{8080, 8080}}, wantString: "[8080]"},
+		{
+			name:       "ranges",
+			args:       []string{"-p", "8000-8999", "-p", "9100-9200", "-p", "1-65535"},
+			want:       []flagr.PortRange{{8000, 8999}, {9100, 9200}, {1, 65535}},
+			wantString: "[8000-8999, 9100-9200, 1-65535]",
+		},
+		{name: "same bounds", args: []string{"-p", "22-22"}, want: []flagr.PortRange{{22, 22}}, wantString: "[22]"},
+		{name: "reversed", args: []string{"-p", "9000-8000"}, wantErr: true},
+		{name: "zero", args: []string{"-p", "0-10"}, wantErr: true},
+		{name: "too high", args: []string{"-p", "65536"}, wantErr: true},
+		{name: "negative", args: []string{"-p", "-1"}, wantErr: true},
+		{name: "open", args: []string{"-p", "10-"}, wantErr: true},
+		{name: "not a number", args: []string{"-p", "http"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "p", flagr.PortRanges("80"), "")
+			err := set.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("p").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestStructFlag(t *testing.T) {
+	type DB struct {
+		Host    string `usage:"database host"`
+		Port    uint16
+		Timeout time.Duration `flag:"connect-timeout"`
+		TLS     bool
+		Skipped string `flag:"-"`
+		private string
+	}
+
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	db := flagr.StructFlag(set, "db", DB{Host: "localhost", Port: 5432, Skipped: "x"})
+
+	if err := set.Parse([]string{"-db.host", "db.internal", "-db.port", "6543", "-db.connect-timeout", "5s", "-db.tls"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := DB{Host: "db.internal", Port: 6543, Timeout: 5 * time.Second, TLS: true, Skipped: "x"}
+	if diff := cmp.Diff(want, *db, cmp.AllowUnexported(DB{})); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if f := set.Lookup("db.host"); f == nil || f.Usage != "database host" || f.DefValue != "localhost" {
+		t.Errorf("db.host = %+v, want usage and default to be set", f)
+	}
+	if set.Lookup("db.skipped") != nil || set.Lookup("db.private") != nil {
+		t.Errorf("skipped or private fields should not be defined")
+	}
+	if err := set.Set("", "db.port", "70000"); err == nil {
+		t.Errorf("out of range port err = nil, want error")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("unsupported field did not panic")
+			}
+		}()
+		flagr.StructFlag(set, "bad", struct{ Tags []string }{})
+	}()
+}
+
+func TestDistribution(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       []float64
+		wantString string
+		wantErr    string
+	}{
+		{in: "0.7,0.2,0.1", want: []float64{0.7, 0.2, 0.1}, wantString: "0.7,0.2,0.1"},
+		{in: "1", want: []float64{1}, wantString: "1"},
+		{in: "0.333, 0.333, 0.333", want: []float64{0.333, 0.333, 0.333}, wantString: "0.333,0.333,0.333"},
+		{in: "1.2,-0.2", wantErr: "invalid weight 1.2, must be in range [0, 1]"},
+		{in: "0.5,0.4", wantErr: "invalid distribution, weights must sum to 1 but they sum to 0.9"},
+		{in: "0.5,x", wantErr: `invalid weight "x": strconv.ParseFloat: parsing "x": invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "split", flagr.Distribution(0.01, 0.5, 0.5), "")
+
+			err := set.Set("", "split", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if diff := cmp.Diff([]float64{0.5, 0.5}, *v); diff != "" {
+					t.Errorf("value changed on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("split").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestCron(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		in       string
+		seconds  bool
+		wantNext time.Time
+		wantErr  string
+	}{
+		{in: "*/15 * * * *", wantNext: time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)},
+		{in: "0 9 * * mon-fri", wantNext: time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{in: "0 0 1 jan,jul *", wantNext: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "0 0 29 2 *", wantNext: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{in: "0 12 * * 7", wantNext: time.Date(2024, time.February, 4, 12, 0, 0, 0, time.UTC)},
+		{in: "0 0 15 * sat", wantNext: time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+		{in: "45 17 10 * * *", seconds: true, wantNext: time.Date(2024, time.January, 31, 10, 17, 45, 0, time.UTC)},
+		{in: "61 * * * *", wantErr: `invalid cron expression "61 * * * *": invalid minute field value 61, must be in range [0, 59]`},
+		{in: "* * * foo *", wantErr: `invalid cron expression "* * * foo *": invalid month field value "foo"`},
+		{in: "* 5-2 * * *", wantErr: `invalid cron expression "* 5-2 * * *": invalid hour field "5-2", 5 is greater than 2`},
+		{in: "*/0 * * * *", wantErr: `invalid cron expression "*/0 * * * *": invalid minute field "*/0", invalid step "0"`},
+		{in: "* * * *", wantErr: `invalid cron expression "* * * *", must have 5 fields`},
+		{in: "* * * * * *", wantErr: `invalid cron expression "* * * * * *", must have 5 fields`},
+		{in: "* * * * *", seconds: true, wantErr: `invalid cron expression "* * * * *", must have 6 fields`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			var opts []flagr.CronOption
+			if tt.seconds {
+				opts = append(opts, flagr.CronSeconds())
+			}
+			v := flagr.Add(set, "schedule", flagr.Cron("", opts...), "")
+
+			err := set.Set("", "schedule", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.Next(from); !got.Equal(tt.wantNext) {
+				t.Errorf("Next() = %v, want %v", got, tt.wantNext)
+			}
+			if got := set.Lookup("schedule").Value.String(); got != tt.in {
+				t.Errorf("String() = %q, want %q", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestCronDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	est := time.FixedZone("EST", -5*60*60)
+	edt := time.FixedZone("EDT", -4*60*60)
+	tests := []struct {
+		name     string
+		in       string
+		from     time.Time
+		wantNext time.Time
+	}{
+		{
+			name:     "skipped by spring forward",
+			in:       "30 2 * * *",
+			from:     time.Date(2026, time.March, 8, 0, 0, 0, 0, ny),
+			wantNext: time.Date(2026, time.March, 9, 2, 30, 0, 0, edt),
+		},
+		{
+			name:     "hourly across spring forward",
+			in:       "15 * * * *",
+			from:     time.Date(2026, time.March, 8, 1, 30, 0, 0, ny),
+			wantNext: time.Date(2026, time.March, 8, 3, 15, 0, 0, edt),
+		},
+		{
+			name:     "first pass of fall back",
+			in:       "11 * * * *",
+			from:     time.Date(2026, time.November, 1, 1, 10, 0, 0, edt),
+			wantNext: time.Date(2026, time.November, 1, 1, 11, 0, 0, edt),
+		},
+		{
+			name:     "second pass of fall back",
+			in:       "11 * * * *",
+			from:     time.Date(2026, time.November, 1, 1, 10, 0, 0, est),
+			wantNext: time.Date(2026, time.November, 1, 1, 11, 0, 0, est),
+		},
+		{
+			name:     "daily across fall back",
+			in:       "0 3 * * *",
+			from:     time.Date(2026, time.October, 31, 12, 0, 0, 0, ny),
+			wantNext: time.Date(2026, time.November, 1, 3, 0, 0, 0, est),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set flagr.Set
+			v := flagr.Add(&set, "v", flagr.Cron(tt.in), "")
+			got := v.Next(tt.from.In(ny))
+			if !got.Equal(tt.wantNext) {
+				t.Errorf("Next() = %v, want %v", got, tt.wantNext)
+			}
+			if !got.After(tt.from) {
+				t.Errorf("Next() = %v, not after %v", got, tt.from)
+			}
+		})
+	}
+}
+
+func TestGlobs(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	include := flagr.Add(set, "include", flagr.Globs(",", "*.md"), "")
+
+	if err := set.Parse([]string{"-include", "*.go, cmd/*", "-include", "testdata/[a-c]?.json"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(flagr.GlobPatterns{"*.go", "cmd/*", "testdata/[a-c]?.json"}, *include); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for path, want := range map[string]bool{
+		"main.go":             true,
+		"cmd/tool":            true,
+		"cmd/tool/main.go":    false,
+		"testdata/b1.json":    true,
+		"testdata/d1.json":    false,
+		"README.md":           false,
+		"internal/x/x_test.g": false,
+	} {
+		if got := include.Matches(path); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	err := set.Set("", "include", "*.go,[")
+	if want := `invalid glob "[": syntax error in pattern`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestWarnOnDefault(t *testing.T) {
+	var buf bytes.Buffer
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(&buf)
+	flagr.Add(set, "secret", flagr.String("changeme"), "")
+	flagr.Add(set, "token", flagr.String("changeme"), "")
+	flagr.Add(set, "addr", flagr.String(":8080"), "")
+	flagr.Add(set, "db", flagr.String("sqlite"), "")
+	set.WarnOnDefault("secret", "token", "addr", "nope")
+
+	err := set.Parse([]string{"-addr", ":80"}, func(set *flagr.Set) error {
+		return set.Set("env", "token", "from-env")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "warning: flag -secret was not set, using its default value\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTristate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    flagr.TriState
+		wantErr string
+	}{
+		{in: "auto", want: flagr.Auto},
+		{in: "AUTO", want: flagr.Auto},
+		{in: "always", want: flagr.Always},
+		{in: "Always", want: flagr.Always},
+		{in: "true", want: flagr.Always},
+		{in: "on", want: flagr.Always},
+		{in: "never", want: flagr.Never},
+		{in: "NEVER", want: flagr.Never},
+		{in: "false", want: flagr.Never},
+		{in: "no", want: flagr.Never},
+		{in: "sometimes", wantErr: `invalid value "sometimes", must be one of: auto, always, never`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "color", flagr.Tristate(flagr.Never), "")
+
+			err := set.Set("", "color", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tt.want {
+				t.Errorf("got %v, want %v", *v, tt.want)
+			}
+		})
+	}
+
+	t.Run("requires a value", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		flagr.Add(set, "color", flagr.Tristate(flagr.Auto), "")
+		if err := set.Parse([]string{"-color"}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		yes := func() bool { return true }
+		no := func() bool { return false }
+		if !flagr.Auto.Resolve(yes) || flagr.Auto.Resolve(no) {
+			t.Error("Auto should defer to autoFn")
+		}
+		if !flagr.Always.Resolve(no) {
+			t.Error("Always should resolve to true")
+		}
+		if flagr.Never.Resolve(yes) {
+			t.Error("Never should resolve to false")
+		}
+	})
+}
+
+func TestTuple3(t *testing.T) {
+	parseFloat := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	parseString := func(s string) (string, error) { return s, nil }
+
+	type triple = flagr.Triple[float64, float64, string]
+	tests := []struct {
+		in         string
+		want       triple
+		wantString string
+		wantErr    string
+	}{
+		{in: "1.5,2.0,label", want: triple{1.5, 2, "label"}, wantString: "1.5,2,label"},
+		{in: "-1,0,", want: triple{-1, 0, ""}, wantString: "-1,0,"},
+		{in: "1.5,2.0", wantErr: `invalid tuple "1.5,2.0", must have 3 fields separated by "," but has 2`},
+		{in: "1,2,3,4", wantErr: `invalid tuple "1,2,3,4", must have 3 fields separated by "," but has 4`},
+		{in: "1,y,z", wantErr: `invalid tuple "1,y,z", field 2: strconv.ParseFloat: parsing "y": invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "point", flagr.Tuple3(",", parseFloat, parseFloat, parseString, "0,0,origin"), "")
+
+			err := set.Set("", "point", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if diff := cmp.Diff(triple{0, 0, "origin"}, *v); diff != "" {
+					t.Errorf("value changed on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("point").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+
+	t.Run("usage", func(t *testing.T) {
+		got := printDefaults(t, func(set *flagr.Set) {
+			flagr.Add(set, "point", flagr.Tuple3(",", parseFloat, parseFloat, parseString, "0,0,origin"), "")
+		})
+		if want := "  -point value\n    \t (default 0,0,origin)\n"; got != want {
+			t.Errorf("PrintDefaults() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestFlagsBySource(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "addr", flagr.String(""), "")
+	flagr.Add(set, "debug", flagr.Bool(false), "")
+	flagr.Add(set, "token", flagr.String(""), "")
+	flagr.Add(set, "user", flagr.String(""), "")
+	flagr.Add(set, "workers", flagr.Int(1), "")
+
+	if diff := cmp.Diff(map[flagr.Source][]string{}, set.FlagsBySource()); diff != "" {
+		t.Errorf("before parse mismatch (-want +got):\n%s", diff)
+	}
+
+	err := set.Parse([]string{"-debug", "-addr", ":80"}, func(set *flagr.Set) error {
+		if err := set.Set("env", "user", "u"); err != nil {
+			return err
+		}
+		return set.Set("env", "token", "t")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[flagr.Source][]string{
+		flagr.SourceDefaultVal: {"workers"},
+		flagr.SourceFlags:      {"addr", "debug"},
+		"env":                  {"token", "user"},
+	}
+	if diff := cmp.Diff(want, set.FlagsBySource()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMapParser(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "addr", flagr.String(":8080"), "")
+	flagr.Add(set, "user", flagr.String(""), "")
+	flagr.Add(set, "workers", flagr.Int(1), "")
+	flagr.Add(set, "debug", flagr.Bool(false), "")
+
+	err := set.Parse(
+		[]string{"-addr", ":80"},
+		flagr.MapParser(map[string]string{"addr": ":90", "workers": "4", "unknown": "x"}, "first"),
+		flagr.MapParser(map[string]string{"workers": "8", "user": "u"}, "second"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]flagr.FlagProvenance{
+		"addr":    {Source: flagr.SourceFlags, Value: ":80"},
+		"user":    {Source: "second", Value: "u"},
+		"workers": {Source: "first", Value: "4"},
+		"debug":   {Source: flagr.SourceDefaultVal, Value: "false"},
+	}
+	if diff := cmp.Diff(want, set.Provenance()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	set = flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "workers", flagr.Int(1), "")
+	err = set.Parse(nil, flagr.MapParser(map[string]string{"workers": "many"}, "map"))
+	if err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestToggles(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "modules", flagr.Toggles(map[string]bool{"cache": true, "metrics": true}), "")
+
+	err := set.Parse([]string{"-modules", "metrics=off, tracing=ON", "-modules", "tracing=false,auth=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"auth": true, "cache": true, "metrics": false, "tracing": false}
+	if diff := cmp.Diff(want, *v); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := set.Lookup("modules").Value.String(), "auth=on,cache=on,metrics=off,tracing=off"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for in, wantErr := range map[string]string{
+		"cache=maybe":        `invalid state "maybe" for "cache", must be one of: on, off, true, false`,
+		"auth=off,cache=yes": `invalid state "yes" for "cache", must be one of: on, off, true, false`,
+		"cache":              `invalid toggle "cache", must be in the form name=on|off`,
+		"=on":                `invalid toggle "=on", must be in the form name=on|off`,
+	} {
+		err := set.Set("", "modules", in)
+		if err == nil || err.Error() != wantErr {
+			t.Errorf("Set(%q) err = %v, want %q", in, err, wantErr)
+		}
+	}
+	if diff := cmp.Diff(want, *v); diff != "" {
+		t.Errorf("value changed on error (-want +got):\n%s", diff)
+	}
+}
+
+func TestTypeNamer(t *testing.T) {
+	var set flagr.Set
+	flagr.Add(&set, "addr", flagr.MustIPAddrPort("127.0.0.1:80"), "listen on")
+	flagr.Add(&set, "timeouts", flagr.Durations(), "the timeouts")
+	flagr.Add(&set, "peer", flagr.MustIPAddrPort("127.0.0.1:80"), "the `peer` to dial")
+	flagr.Add(&set, "v", flagr.Bool(false), "verbose")
+	set.SetTypeNamer(flagr.GoTypeName)
+
+	var buf bytes.Buffer
+	set.SetOutput(&buf)
+	set.PrintDefaults()
+	want := `  -addr netip.AddrPort
+    	listen on (default 127.0.0.1:80)
+  -peer peer
+    	the peer to dial (default 127.0.0.1:80)
+  -timeouts []time.Duration
+    	the timeouts (default [])
+  -v	verbose (default false)
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStringsMin(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "exactly the minimum", args: []string{"-endpoints", "a,b", "-endpoints", "c"}, want: []string{"a", "b", "c"}},
+		{name: "duplicates", args: []string{"-endpoints", "a,b,a"}, wantErr: `invalid value "[a, b, a]" for flag -endpoints: got 2 distinct values, need at least 3`},
+		{name: "fewer than the minimum", args: []string{"-endpoints", "a"}, wantErr: `invalid value "[a]" for flag -endpoints: got 1 distinct values, need at least 3`},
+		{name: "defaults are validated", wantErr: `invalid value "[x]" for flag -endpoints: got 1 distinct values, need at least 3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "endpoints", flagr.StringsMin(3, ",", "x"), "")
+
+			err := set.Parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("usage", func(t *testing.T) {
+		got := printDefaults(t, func(set *flagr.Set) {
+			flagr.Add(set, "peers", flagr.StringsMin(2, ",", "a,b"), "")
+		})
+		if want := "  -peers value\n    \t (default [a, b])\n"; got != want {
+			t.Errorf("PrintDefaults() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestIndirect(t *testing.T) {
+	resolvers := map[string]func(string) (string, error){
+		"env": func(name string) (string, error) {
+			if name != "TOKEN" {
+				return "", errors.New(name + " is not set")
+			}
+			return "from-env", nil
+		},
+		"file": func(path string) (string, error) {
+			return "contents of " + path, nil
+		},
+	}
+
+	tests := []struct {
+		in      string
+		want    flagr.IndirectValue
+		wantErr string
+	}{
+		{in: "env:TOKEN", want: flagr.IndirectValue{Origin: "env", Raw: "env:TOKEN", Value: "from-env"}},
+		{in: "file:/run/secret", want: flagr.IndirectValue{Origin: "file", Raw: "file:/run/secret", Value: "contents of /run/secret"}},
+		{in: "plain", want: flagr.IndirectValue{Origin: "literal", Raw: "plain", Value: "plain"}},
+		{in: "Host:80", want: flagr.IndirectValue{Origin: "literal", Raw: "Host:80", Value: "Host:80"}},
+		{in: "literal:env:TOKEN", want: flagr.IndirectValue{Origin: "literal", Raw: "literal:env:TOKEN", Value: "env:TOKEN"}},
+		{in: "db:5432", want: flagr.IndirectValue{Origin: "literal", Raw: "db:5432", Value: "db:5432"}},
+		{in: "http://x", want: flagr.IndirectValue{Origin: "literal", Raw: "http://x", Value: "http://x"}},
+		{in: "env", want: flagr.IndirectValue{Origin: "literal", Raw: "env", Value: "env"}},
+		{in: "env:OTHER", wantErr: `cannot resolve "env:OTHER": OTHER is not set`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "token", flagr.Indirect("default", resolvers), "")
+
+			err := set.Set("", "token", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if want := (flagr.IndirectValue{Origin: "literal", Raw: "default", Value: "default"}); *v != want {
+					t.Errorf("value changed on error: %+v", *v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tt.want {
+				t.Errorf("got %+v, want %+v", *v, tt.want)
+			}
+			if got := set.Lookup("token").Value.String(); got != tt.in {
+				t.Errorf("String() = %q, want %q", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "addr", flagr.String(":8080"), "")
+	flagr.Add(set, "user", flagr.String(""), "")
+	flagr.Add(set, "workers", flagr.Int(1), "")
+
+	err := set.Parse([]string{"-addr", ":8080"}, flagr.MapParser(map[string]string{"user": "u"}, "env: USER"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"addr":    true,
+		"user":    true,
+		"workers": false,
+		"nope":    false,
+	} {
+		if got := set.Changed(name); got != want {
+			t.Errorf("Changed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	duration := func(s string) (any, error) { return time.ParseDuration(s) }
+	float := func(s string) (any, error) { return strconv.ParseFloat(s, 64) }
+
+	tests := []struct {
+		in         string
+		want       map[string]any
+		wantString string
+		wantErr    string
+	}{
+		{
+			in:         "max=10s, initial=100ms,factor=2",
+			want:       map[string]any{"initial": 100 * time.Millisecond, "max": 10 * time.Second, "factor": 2.0},
+			wantString: "initial=100ms,max=10s,factor=2",
+		},
+		{
+			in:         "initial=1s,max=1m",
+			want:       map[string]any{"initial": time.Second, "max": time.Minute},
+			wantString: "initial=1s,max=1m0s",
+		},
+		{in: "initial=1s", wantErr: `missing required field "max"`},
+		{in: "initial=1s,max=1m,jitter=0.1", wantErr: `unknown field "jitter", must be one of: initial, max, factor`},
+		{in: "initial=1s,initial=2s,max=1m", wantErr: `duplicate field "initial"`},
+		{in: "initial=fast,max=1m", wantErr: `invalid field "initial": time: invalid duration "fast"`},
+		{in: "initial", wantErr: `invalid field "initial", must be in the form name=value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			backoff := flagr.Fields(",").
+				Required("initial", duration).
+				Required("max", duration).
+				Optional("factor", float).
+				Getter("initial=1s,max=30s")
+			v := flagr.Add(set, "backoff", backoff, "")
+
+			err := set.Set("", "backoff", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := set.Lookup("backoff").Value.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+
+	t.Run("usage", func(t *testing.T) {
+		got := printDefaults(t, func(set *flagr.Set) {
+			backoff := flagr.Fields(",").
+				Required("initial", duration).
+				Required("max", duration).
+				Getter("initial=1s,max=30s")
+			flagr.Add(set, "backoff", backoff, "")
+		})
+		if want := "  -backoff value\n    \t (default initial=1s,max=30s)\n"; got != want {
+			t.Errorf("PrintDefaults() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestOptionalParser(t *testing.T) {
+	var buf bytes.Buffer
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(&buf)
+	addr := flagr.Add(set, "addr", flagr.String(""), "")
+	user := flagr.Add(set, "user", flagr.String(""), "")
+
+	remote := func(set *flagr.Set) error {
+		if err := set.Set("remote", "addr", ":80"); err != nil {
+			return err
+		}
+		return errors.New("connection refused")
+	}
+	err := set.Parse(nil,
+		flagr.Optional(remote),
+		flagr.MapParser(map[string]string{"addr": ":90", "user": "u"}, "file"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ":80"; *addr != want {
+		t.Errorf("addr = %q, want %q", *addr, want)
+	}
+	if want := "u"; *user != want {
+		t.Errorf("user = %q, want %q", *user, want)
+	}
+	if want := "warning: optional parser failed: connection refused\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      flagr.QuantityValue
+		wantMilli int64
+		wantErr   string
+	}{
+		{in: "500m", want: flagr.QuantityValue{Value: 0.5, Number: "500", Suffix: "m"}, wantMilli: 500},
+		{in: "1Gi", want: flagr.QuantityValue{Value: 1 << 30, Number: "1", Suffix: "Gi"}, wantMilli: 1 << 30 * 1000},
+		{in: "2.5", want: flagr.QuantityValue{Value: 2.5, Number: "2.5"}, wantMilli: 2500},
+		{in: "1.5k", want: flagr.QuantityValue{Value: 1500, Number: "1.5", Suffix: "k"}, wantMilli: 1500000},
+		{in: "1e3", want: flagr.QuantityValue{Value: 1000, Number: "1e3"}, wantMilli: 1000000},
+		{in: "1GB", wantErr: `invalid quantity "1GB", unknown suffix "GB", must be one of: n, u, m, k, M, G, T, P, E, Ki, Mi, Gi, Ti, Pi, Ei`},
+		{in: "1K", wantErr: `invalid quantity "1K", unknown suffix "K", must be one of: n, u, m, k, M, G, T, P, E, Ki, Mi, Gi, Ti, Pi, Ei`},
+		{in: "Gi", wantErr: `invalid quantity "Gi", must be a number with an optional suffix`},
+		{in: "1.2.3m", wantErr: `invalid quantity "1.2.3m", must be a number with an optional suffix`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "mem", flagr.Quantity("1"), "")
+
+			err := set.Set("", "mem", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got := v.MilliValue(); got != tt.wantMilli {
+				t.Errorf("MilliValue() = %d, want %d", got, tt.wantMilli)
+			}
+			if got := set.Lookup("mem").Value.String(); got != tt.in {
+				t.Errorf("String() = %q, want %q", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{in: "remote,cache,db", want: []string{"remote", "cache", "db"}},
+		{in: "db", want: []string{"db"}},
+		{in: "cache, db", want: []string{"cache", "db"}},
+		{in: "cache,disk", wantErr: `invalid item "disk", must be one of: cache, db, remote`},
+		{in: "cache,db,cache", wantErr: `duplicate item "cache"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "order", flagr.Ordering([]string{"cache", "db", "remote"}, "cache", "db"), "")
+
+			err := set.Set("", "order", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if diff := cmp.Diff([]string{"cache", "db"}, *v); diff != "" {
+					t.Errorf("value changed on error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, *v); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if got, want := set.Lookup("order").Value.String(), strings.Join(tt.want, ","); got != want {
+				t.Errorf("String() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestIntStep(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr string
+	}{
+		{in: "4096", want: 4096},
+		{in: "65536", want: 65536},
+		{in: "0x2000", want: 8192},
+		{in: "5000", wantErr: "invalid value 5000, must be a positive multiple of 4096, nearest valid values are 4096 and 8192"},
+		{in: "100", wantErr: "invalid value 100, must be a positive multiple of 4096, nearest valid value is 4096"},
+		{in: "0", wantErr: "invalid value 0, must be a positive multiple of 4096, nearest valid value is 4096"},
+		{in: "-4096", wantErr: "invalid value -4096, must be a positive multiple of 4096, nearest valid value is 4096"},
+		{in: "4k", wantErr: `strconv.ParseInt: parsing "4k": invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "block-size", flagr.IntStep(4096, 4096), "")
+
+			err := set.Set("", "block-size", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if *v != 4096 {
+					t.Errorf("value changed on error: %d", *v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tt.want {
+				t.Errorf("got %d, want %d", *v, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		v := flagr.Add(set, "align", flagr.UintStep(8, 8), "")
+		if err := set.Set("", "align", "24"); err != nil || *v != 24 {
+			t.Errorf("got %d, %v, want 24", *v, err)
+		}
+		err := set.Set("", "align", "30")
+		if want := "invalid value 30, must be a positive multiple of 8, nearest valid values are 24 and 32"; err == nil || err.Error() != want {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	})
+}
+
+func TestIntBase(t *testing.T) {
+	tests := []struct {
+		name    string
+		getter  flagr.Getter[int]
+		in      string
+		want    int
+		wantErr string
+	}{
+		{name: "base 2", getter: flagr.IntBase(0, 2), in: "1010", want: 10},
+		{name: "base 2 negative", getter: flagr.IntBase(0, 2), in: "-11", want: -3},
+		{name: "base 16", getter: flagr.IntBase(0, 16), in: "ff", want: 255},
+		{name: "base 16 uppercase", getter: flagr.IntBase(0, 16), in: "FF", want: 255},
+		{name: "base 36", getter: flagr.IntBase(0, 36), in: "zz", want: 1295},
+		{name: "base 16 with prefix", getter: flagr.IntBase(0, 16), in: "0xff", wantErr: `strconv.ParseInt: parsing "0xff": invalid syntax`},
+		{name: "base 2 invalid digit", getter: flagr.IntBase(0, 2), in: "102", wantErr: `strconv.ParseInt: parsing "102": invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flagr.NewSet("", flagr.ContinueOnError)
+			set.SetOutput(ioutil.Discard)
+			v := flagr.Add(set, "id", tt.getter, "")
+
+			err := set.Set("", "id", tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *v != tt.want {
+				t.Errorf("got %d, want %d", *v, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsigned slice", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		v := flagr.Add(set, "masks", flagr.UintsBase(16, 1), "")
+		if err := set.Parse([]string{"-masks", "ff", "-masks", "10"}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]uint{255, 16}, *v); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid base", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		flagr.IntBase(0, 37)
+	})
+}
+
+func TestIPOrPrefix(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	v := flagr.Add(set, "allow", flagr.IPOrPrefix("127.0.0.1"), "")
+
+	err := set.Parse([]string{
+		"-allow", "10.0.0.5",
+		"-allow", "10.0.0.0/24",
+		"-allow", "2001:db8::1",
+		"-allow", "2001:db8::/32",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.5/32"),
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("2001:db8::1/128"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	if diff := cmp.Diff(want, *v, cmp.Comparer(func(a, b netip.Prefix) bool { return a == b })); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := set.Lookup("allow").Value.String(), "[10.0.0.5/32, 10.0.0.0/24, 2001:db8::1/128, 2001:db8::/32]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	err = set.Set("", "allow", "10.0.0.300")
+	if want := `invalid IP or prefix "10.0.0.300"`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "addr", flagr.String(":8080"), "")
+	flagr.Add(set, "user", flagr.String(""), "")
+
+	set.EnableTrace()
+	start := time.Now()
+	if err := set.Set("env: APP_ADDR", "addr", ":90"); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Set("env: APP_ADDR", "addr", "invalid value is still fine for strings"); err != nil {
+		t.Fatal(err)
+	}
+	err := set.Parse([]string{"-addr", ":80"}, flagr.MapParser(map[string]string{"user": "u"}, "map"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := set.Trace()
+	for i, e := range trace {
+		if e.When.Before(start) || i > 0 && e.When.Before(trace[i-1].When) {
+			t.Errorf("entry %d has an out of order timestamp", i)
+		}
+	}
+	want := []flagr.TraceEntry{
+		{Name: "addr", Value: ":90", Source: "env: APP_ADDR"},
+		{Name: "addr", Value: "invalid value is still fine for strings", Source: "env: APP_ADDR"},
+		{Name: "addr", Value: ":80", Source: flagr.SourceFlags},
+		{Name: "user", Value: "u", Source: "map"},
+	}
+	if diff := cmp.Diff(want, trace, cmpopts.IgnoreFields(flagr.TraceEntry{}, "When")); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestURLList(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	opts := []flagr.URLOption{flagr.URLSchemes("http", "https")}
+	endpoints := flagr.Add(set, "endpoints", flagr.URLList(",", opts, testflags.MustURL("https://go.dev")), "")
+
+	if err := set.Parse([]string{"-endpoints", "https://a.example, HTTP://b.example/x"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []*url.URL{testflags.MustURL("https://a.example"), testflags.MustURL("HTTP://b.example/x")}
+	if diff := cmp.Diff(want, *endpoints); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for value, want := range map[string]string{
+		"https://a.example,/relative": `invalid url "/relative": must be absolute`,
+		"ftp://a.example":             `invalid url "ftp://a.example": scheme "ftp" is not allowed, must be one of: http, https`,
+		"https://a.example,%zz":       `invalid url "%zz": parse "%zz": invalid URL escape "%zz"`,
+	} {
+		err := set.Set("", "endpoints", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestDurationDefaultUnit(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	timeout := flagr.Add(set, "timeout", flagr.DurationDefaultUnit(time.Second, 5*time.Second), "")
+
+	for value, want := range map[string]time.Duration{
+		"30":    30 * time.Second,
+		"500ms": 500 * time.Millisecond,
+		"-5":    -5 * time.Second,
+		"0":     0,
+		"1m30s": 90 * time.Second,
+		"2.5":   2500 * time.Millisecond,
+		"-0.25": -250 * time.Millisecond,
+		".5":    500 * time.Millisecond,
+	} {
+		if err := set.Set("", "timeout", value); err != nil {
+			t.Errorf("Set(%q) = %v", value, err)
+			continue
+		}
+		if *timeout != want {
+			t.Errorf("Set(%q) = %v, want %v", value, *timeout, want)
+		}
+	}
+
+	for value, want := range map[string]string{
+		"1.5x":                `time: unknown unit "x" in duration "1.5x"`,
+		"NaN":                 `time: invalid duration "NaN"`,
+		"9223372036854775807": `duration "9223372036854775807" overflows when interpreted in 1s`,
+		"9223372036.9":        `duration "9223372036.9" overflows when interpreted in 1s`,
+	} {
+		err := set.Set("", "timeout", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestHostname(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	host := flagr.Add(set, "host", flagr.Hostname("localhost"), "")
+	fqdn := flagr.Add(set, "fqdn", flagr.Hostname("", flagr.AllowTrailingDot()), "")
+	peers := flagr.Add(set, "peer", flagr.Hostnames(nil, "a.example"), "")
+
+	err := set.Parse([]string{"-host", "api-1.Example.com", "-fqdn", "example.com.", "-peer", "b.example", "-peer", "c.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"api-1.Example.com", "example.com."}, []string{*host, *fqdn}); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"b.example", "c.example"}, *peers); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	long := strings.Repeat("a", 64)
+	for value, want := range map[string]string{
+		long + ".example":               `invalid hostname "` + long + `.example": label "` + long + `" exceeds 63 characters`,
+		"exa_mple.com":                  `invalid hostname "exa_mple.com": label "exa_mple" contains invalid character '_'`,
+		"-example.com":                  `invalid hostname "-example.com": label "-example" must not start or end with a hyphen`,
+		"example..com":                  `invalid hostname "example..com": empty label`,
+		"example.com.":                  `invalid hostname "example.com.": trailing dot is not allowed`,
+		strings.Repeat("a.", 127) + "a": `invalid hostname "` + strings.Repeat("a.", 127) + `a": length 255 exceeds 253 characters`,
+	} {
+		err := set.Set("", "host", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestEnvDefault(t *testing.T) {
+	t.Setenv("FLAGR_TEST_PORT", "9090")
+
+	var buf bytes.Buffer
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(&buf)
+	port := flagr.Add(set, "port", flagr.EnvDefault("FLAGR_TEST_PORT", "8080", flagr.Int(0)), "listen port")
+	workers := flagr.Add(set, "workers", flagr.EnvDefault("FLAGR_TEST_WORKERS", "4", flagr.Int(0)), "")
+	tags := flagr.Add(set, "tags", flagr.EnvDefault("FLAGR_TEST_TAGS", "", flagr.Strings("a")), "")
+
+	if *port != 9090 || *workers != 4 {
+		t.Errorf("port, workers = %d, %d, want 9090, 4", *port, *workers)
+	}
+	set.PrintDefaults()
+	if want := "listen port (default 9090)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("usage does not contain %q:\n%s", want, buf.String())
+	}
+
+	if err := set.Parse([]string{"-tags", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b"}, *tags); diff != "" {
+		t.Errorf("tags mismatch (-want +got):\n%s", diff)
+	}
+
+	info, _ := set.SourceInfo("port")
+	if diff := cmp.Diff(flagr.SourceInfo{Kind: "env-default", Detail: "FLAGR_TEST_PORT"}, info); diff != "" {
+		t.Errorf("port info mismatch (-want +got):\n%s", diff)
+	}
+	if src := set.Provenance()["workers"].Source; src != flagr.SourceDefaultVal {
+		t.Errorf("workers source = %q, want %q", src, flagr.SourceDefaultVal)
+	}
+	if set.Changed("port") {
+		t.Error("port is reported as changed")
+	}
+
+	if err := set.Set("env", "port", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if src := set.Provenance()["port"].Source; src != "env" {
+		t.Errorf("port source = %q, want %q", src, "env")
+	}
+}
+
+func TestCIDRSet(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	allow := flagr.Add(set, "allow", flagr.CIDRSet(",", netip.MustParsePrefix("127.0.0.0/8")), "")
+
+	if err := set.Parse([]string{"-allow", "10.0.0.0/8, 192.168.1.0/24", "-allow", "fd00::/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[10.0.0.0/8, 192.168.1.0/24, fd00::/8]"; set.Lookup("allow").Value.String() != want {
+		t.Errorf("String() = %q, want %q", set.Lookup("allow").Value.String(), want)
+	}
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3":          true,
+		"192.168.1.200":     true,
+		"192.168.2.1":       false,
+		"127.0.0.1":         false,
+		"fd12::1":           true,
+		"fe80::1":           false,
+		"::ffff:10.0.0.1":   true,
+		"::ffff:172.16.0.1": false,
+	} {
+		if got := allow.Contains(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	err := set.Set("", "allow", "10.0.0.0/8,10.0.0.1")
+	if want := `invalid prefix "10.0.0.1"`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	addr := flagr.Add(set, "addr", flagr.String(":8080"), "")
+	tags := flagr.Add(set, "tag", flagr.Strings("a", "b"), "")
+	hosts := flagr.Add(set, "host", flagr.Globs(",", "*.local"), "")
+	user := flagr.Add(set, "user", flagr.String("nobody"), "")
+	workers := flagr.Add(set, "workers", flagr.Int(1), "")
+	if err := set.SetDefault("workers", "4"); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-addr", ":80", "-tag", "x", "-tag", "y", "-host", "a.*,b.*"}
+	parser := flagr.MapParser(map[string]string{"user": "root"}, "map")
+	type values struct {
+		Addr    string
+		Tags    []string
+		Hosts   flagr.GlobPatterns
+		User    string
+		Workers int
+	}
+	snapshot := func() values { return values{*addr, *tags, *hosts, *user, *workers} }
+
+	if err := set.Parse(args, parser); err != nil {
+		t.Fatal(err)
+	}
+	wantValues, wantProvenance := snapshot(), set.Provenance()
+
+	if err := set.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	defaults := values{":8080", []string{"a", "b"}, flagr.GlobPatterns{"*.local"}, "nobody", 4}
+	if diff := cmp.Diff(defaults, snapshot()); diff != "" {
+		t.Errorf("values after Reset mismatch (-want +got):\n%s", diff)
+	}
+	if set.Changed("addr") {
+		t.Error("addr is reported as changed after Reset")
+	}
+
+	if err := set.Parse(args, parser); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantValues, snapshot()); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantProvenance, set.Provenance()); diff != "" {
+		t.Errorf("provenance mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		type DB struct {
+			Host string
+			Port int
+		}
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		db := flagr.StructFlag(set, "db", DB{Host: "localhost", Port: 5432})
+
+		if err := set.Parse([]string{"-db.host", "remote", "-db.port", "1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(DB{Host: "localhost", Port: 5432}, *db); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestPEMBundle(t *testing.T) {
+	block := func(data string) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(data)}))
+	}
+	fsys := fstest.MapFS{
+		"root.pem":         &fstest.MapFile{Data: []byte(block("root"))},
+		"intermediate.pem": &fstest.MapFile{Data: []byte(block("int1") + "\n" + block("int2"))},
+		"bad.pem":          &fstest.MapFile{Data: []byte(block("root") + "garbage")},
+	}
+
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	ca := flagr.Add(set, "ca", flagr.PEMBundle(",", fsys), "")
+
+	if err := set.Parse([]string{"-ca", "@root.pem, @./intermediate.pem", "-ca", block("inline")}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(block("root")+block("int1")+block("int2")+block("inline"), string(*ca)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want := "4 PEM blocks"; set.Lookup("ca").Value.String() != want {
+		t.Errorf("String() = %q, want %q", set.Lookup("ca").Value.String(), want)
+	}
+
+	for value, want := range map[string]string{
+		"@bad.pem":                  `invalid PEM in "@bad.pem"`,
+		"@root.pem,not pem":         `invalid PEM in inline entry 1`,
+		"@missing.pem":              `unable to read "missing.pem": open missing.pem: file does not exist`,
+		block("a") + "," + "@x.pem": `unable to read "x.pem": open x.pem: file does not exist`,
+	} {
+		err := set.Set("", "ca", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	retry := flagr.Add(set, "retry", flagr.RetryPolicy("3x"), "")
+
+	if err := set.Parse([]string{"-retry", "5x, jitter=0.1,base=200ms, max=5s"}); err != nil {
+		t.Fatal(err)
+	}
+	want := flagr.RetryPolicyValue{Attempts: 5, Base: 200 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.1}
+	if diff := cmp.Diff(want, *retry); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want := "5x,base=200ms,max=5s,jitter=0.1"; retry.String() != want {
+		t.Errorf("String() = %q, want %q", retry.String(), want)
+	}
+	if err := set.Set("", "retry", retry.String()); err != nil || *retry != want {
+		t.Errorf("String() does not round trip: %v, %v", *retry, err)
+	}
+
+	for value, want := range map[string]string{
+		"5x,jitter=1.5":      `invalid retry policy "5x,jitter=1.5", jitter must be a number in [0, 1]`,
+		"5x,jitter=-0.1":     `invalid retry policy "5x,jitter=-0.1", jitter must be a number in [0, 1]`,
+		"0x":                 `invalid retry policy "0x", attempts must be positive`,
+		"5":                  `invalid retry policy "5", must start with the number of attempts, as in 5x`,
+		"5x,delay=1s":        `invalid retry policy "5x,delay=1s", unknown key "delay", must be one of: base, max, jitter`,
+		"5x,base=1s,max=1ms": `invalid retry policy "5x,base=1s,max=1ms", max must not be less than base`,
+	} {
+		err := set.Set("", "retry", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	flagr.Add(set, "verbose", flagr.Bool(false), "")
+
+	defs := []flagr.FlagDef{
+		{Name: "plugin.workers", Usage: "number of workers", Value: flagr.Int(1)},
+		{Name: "plugin.name", Usage: "plugin name", Value: flagr.String("default")},
+		{Name: "plugin.debug", Usage: "debug mode", Value: flagr.Bool(false)},
+		{Name: "plugin.tags", Usage: "tags", Value: flagr.Strings()},
+	}
+	if err := set.AddAll(defs); err != nil {
+		t.Fatal(err)
+	}
+
+	err := set.Parse([]string{"-plugin.workers", "4", "-plugin.debug", "-plugin.tags", "a", "-plugin.tags", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := flagr.Get[int](set, "plugin.workers"); v != 4 {
+		t.Errorf("plugin.workers = %d, want 4", v)
+	}
+	if v, _ := flagr.Get[string](set, "plugin.name"); v != "default" {
+		t.Errorf("plugin.name = %q, want %q", v, "default")
+	}
+	if v, _ := flagr.Get[bool](set, "plugin.debug"); !v {
+		t.Errorf("plugin.debug = %v, want true", v)
+	}
+	if v, _ := set.GetString("plugin.tags"); v != "[a, b]" {
+		t.Errorf("plugin.tags = %q, want %q", v, "[a, b]")
+	}
+
+	for _, defs := range [][]flagr.FlagDef{
+		{{Name: "x", Value: flagr.Int(0)}, {Name: "verbose", Value: flagr.Int(0)}},
+		{{Name: "x", Value: flagr.Int(0)}, {Name: "x", Value: flagr.Int(0)}},
+	} {
+		if err := set.AddAll(defs); !errors.Is(err, flagr.ErrRedefined) {
+			t.Errorf("err = %v, want %v", err, flagr.ErrRedefined)
+		}
+		if set.Lookup("x") != nil {
+			t.Error("flag x was created despite the error")
+		}
+	}
+
+	t.Run("env default and reset", func(t *testing.T) {
+		t.Setenv("FLAGR_TEST_PLUGIN_LEVEL", "debug")
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		err := set.AddAll([]flagr.FlagDef{
+			{Name: "level", Value: flagr.EnvDefault("FLAGR_TEST_PLUGIN_LEVEL", "info", flagr.String(""))},
+			{Name: "tags", Value: flagr.Strings("a")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, _ := set.SourceInfo("level"); info != (flagr.SourceInfo{Kind: "env-default", Detail: "FLAGR_TEST_PLUGIN_LEVEL"}) {
+			t.Errorf("level info = %+v, want env-default", info)
+		}
+
+		if err := set.Parse([]string{"-level", "warn", "-tags", "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		level, _ := set.GetString("level")
+		tags, _ := set.GetString("tags")
+		if level != "debug" || tags != "[a]" {
+			t.Errorf("level, tags = %q, %q after Reset, want %q, %q", level, tags, "debug", "[a]")
+		}
+	})
+}
+
+func TestRatio(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	ratio := flagr.Add(set, "ratio", flagr.Ratio("4:3"), "")
+	supported := flagr.Add(set, "supported", flagr.Ratios("1:1"), "")
+
+	if err := set.Parse([]string{"-ratio", "16:9", "-supported", "4:3", "-supported", "21:9"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(flagr.RatioValue{Num: 16, Den: 9}, *ratio); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := ratio.Float(), 16.0/9; got != want {
+		t.Errorf("Float() = %v, want %v", got, want)
+	}
+	if want := "16:9"; ratio.String() != want {
+		t.Errorf("String() = %q, want %q", ratio.String(), want)
+	}
+	if diff := cmp.Diff([]flagr.RatioValue{{4, 3}, {21, 9}}, *supported); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for value, want := range map[string]string{
+		"16:0":  `invalid ratio "16:0", denominator must not be zero`,
+		"16/9":  `invalid ratio "16/9", must be in the form num:den`,
+		"1.5:1": `invalid ratio "1.5:1", numerator must be an integer`,
+		"16:x":  `invalid ratio "16:x", denominator must be an integer`,
+	} {
+		err := set.Set("", "ratio", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestParseUntilArg(t *testing.T) {
+	parent := flagr.NewSet("app", flagr.ContinueOnError)
+	parent.SetOutput(ioutil.Discard)
+	verbose := flagr.Add(parent, "v", flagr.Bool(false), "")
+	config := flagr.Add(parent, "config", flagr.String(""), "")
+
+	args := []string{"-v", "-config", "app.toml", "serve", "-addr", ":80", "-v", "extra"}
+	n, err := parent.ParseUntilArg(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("consumed = %d, want 3", n)
+	}
+	if !*verbose || *config != "app.toml" {
+		t.Errorf("v, config = %v, %q, want true, %q", *verbose, *config, "app.toml")
+	}
+	if diff := cmp.Diff(args[3:], parent.Args()); diff != "" {
+		t.Errorf("args mismatch (-want +got):\n%s", diff)
+	}
+
+	child := flagr.NewSet("serve", flagr.ContinueOnError)
+	child.SetOutput(ioutil.Discard)
+	addr := flagr.Add(child, "addr", flagr.String(":8080"), "")
+	childVerbose := flagr.Add(child, "v", flagr.Bool(false), "")
+	if err := child.Parse(parent.Args()[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":80" || !*childVerbose {
+		t.Errorf("addr, v = %q, %v, want %q, true", *addr, *childVerbose, ":80")
+	}
+	if diff := cmp.Diff([]string{"extra"}, child.Args()); diff != "" {
+		t.Errorf("child args mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Run("terminator", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		flagr.Add(set, "v", flagr.Bool(false), "")
+		n, err := set.ParseUntilArg([]string{"-v", "--", "-not-a-flag"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("consumed = %d, want 2", n)
+		}
+		if diff := cmp.Diff([]string{"-not-a-flag"}, set.Args()); diff != "" {
+			t.Errorf("args mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		set := flagr.NewSet("", flagr.ContinueOnError)
+		set.SetOutput(ioutil.Discard)
+		if n, err := set.ParseUntilArg([]string{"-addr", ":80", "serve"}); err == nil || n != 0 {
+			t.Errorf("ParseUntilArg() = %d, %v, want 0 and an error", n, err)
+		}
+	})
+}
+
+func TestSortSpec(t *testing.T) {
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	sort := flagr.Add(set, "sort", flagr.SortSpec([]string{"name", "created", "size"}, "name"), "")
+
+	if diff := cmp.Diff([]flagr.SortField{{Field: "name"}}, *sort); diff != "" {
+		t.Errorf("default mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := set.Parse([]string{"-sort", "created:desc, name,size:asc"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []flagr.SortField{{Field: "created", Desc: true}, {Field: "name"}, {Field: "size"}}
+	if diff := cmp.Diff(want, *sort); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want := "created:desc,name:asc,size:asc"; set.Lookup("sort").Value.String() != want {
+		t.Errorf("String() = %q, want %q", set.Lookup("sort").Value.String(), want)
+	}
+
+	for value, want := range map[string]string{
+		"owner":              `invalid sort field "owner", must be one of: name, created, size`,
+		"name:desc,name:asc": `duplicate sort field "name"`,
+		"name:up":            `invalid sort direction "up" for field "name", must be one of: asc, desc`,
+	} {
+		err := set.Set("", "sort", value)
+		if err == nil || err.Error() != want {
+			t.Errorf("Set(%q) err = %v, want %q", value, err, want)
+		}
+	}
+}
+
+// printDefaults returns the output of PrintDefaults for a Set with the flags
+// added by add, failing if any String method panicked.
+func printDefaults(t *testing.T, add func(set *flagr.Set)) string {
+	t.Helper()
+	var buf bytes.Buffer
+	set := flagr.NewSet("", flagr.ContinueOnError)
+	set.SetOutput(&buf)
+	add(set)
+	set.PrintDefaults()
+	if strings.Contains(buf.String(), "panic calling String method") {
+		t.Errorf("PrintDefaults panicked:\n%s", buf.String())
+	}
+	return buf.String()
+}
+
+func TestPrintDefaultsMultiSlice(t *testing.T) {
+	got := printDefaults(t, func(set *flagr.Set) {
+		flagr.Add(set, "cidrs", flagr.CIDRSet(",", netip.MustParsePrefix("10.0.0.0/8")), "")
+		flagr.Add(set, "enums", flagr.EnumSet(",", []string{"a", "b"}, "a"), "")
+		flagr.Add(set, "globs", flagr.Globs(",", "*.go"), "")
+		flagr.Add(set, "lines", flagr.Lines("x"), "")
+		flagr.Add(set, "ranges", flagr.IntRanges(1, 2), "")
+		flagr.Add(set, "schema", flagr.Schema([]string{"id=int"}), "")
+	})
+	want := `  -cidrs value
+    	 (default [10.0.0.0/8])
+  -enums value
+    	 (default a)
+  -globs value
+    	 (default [*.go])
+  -lines value
+    	 (default ["x"])
+  -ranges value
+    	 (default [1, 2])
+  -schema value
+    	 (default id=int)
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
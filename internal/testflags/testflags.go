@@ -1,8 +1,11 @@
 package testflags
 
 import (
+	"net"
 	"net/netip"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/flga/flagr"
@@ -11,163 +14,274 @@ import (
 const TimeLayout = "2006-01-02"
 
 type Flags struct {
-	Int             *int
-	Ints            *[]int
-	Int8            *int8
-	Int8s           *[]int8
-	Int16           *int16
-	Int16s          *[]int16
-	Int32           *int32
-	Int32s          *[]int32
-	Int64           *int64
-	Int64s          *[]int64
-	Uint            *uint
-	Uints           *[]uint
-	Uint8           *uint8
-	Uint8s          *[]uint8
-	Uint16          *uint16
-	Uint16s         *[]uint16
-	Uint32          *uint32
-	Uint32s         *[]uint32
-	Uint64          *uint64
-	Uint64s         *[]uint64
-	Float32         *float32
-	Float32s        *[]float32
-	Float64         *float64
-	Float64s        *[]float64
-	Complex64       *complex64
-	Complex64s      *[]complex64
-	Complex128      *complex128
-	Complex128s     *[]complex128
-	Bool            *bool
-	Bools           *[]bool
-	String          *string
-	Strings         *[]string
-	Duration        *time.Duration
-	Durations       *[]time.Duration
-	Time            *time.Time
-	MustTime        *time.Time
-	Times           *[]time.Time
-	MustTimes       *[]time.Time
-	URL             **url.URL
-	MustURL         **url.URL
-	URLs            *[]*url.URL
-	MustURLs        *[]*url.URL
-	IPAddr          *netip.Addr
-	MustIPAddr      *netip.Addr
-	IPAddrs         *[]netip.Addr
-	MustIPAddrs     *[]netip.Addr
-	IPAddrPort      *netip.AddrPort
-	MustIPAddrPort  *netip.AddrPort
-	IPAddrPorts     *[]netip.AddrPort
-	MustIPAddrPorts *[]netip.AddrPort
+	Int                 *int
+	Ints                *[]int
+	Int8                *int8
+	Int8s               *[]int8
+	Int16               *int16
+	Int16s              *[]int16
+	Int32               *int32
+	Int32s              *[]int32
+	Int64               *int64
+	Int64s              *[]int64
+	Uint                *uint
+	Uints               *[]uint
+	Uint8               *uint8
+	Uint8s              *[]uint8
+	Uint16              *uint16
+	Uint16s             *[]uint16
+	Uint32              *uint32
+	Uint32s             *[]uint32
+	Uint64              *uint64
+	Uint64s             *[]uint64
+	Float32             *float32
+	Float32s            *[]float32
+	Float64             *float64
+	Float64s            *[]float64
+	Complex64           *complex64
+	Complex64s          *[]complex64
+	Complex128          *complex128
+	Complex128s         *[]complex128
+	Bool                *bool
+	Bools               *[]bool
+	String              *string
+	Strings             *[]string
+	Duration            *time.Duration
+	Durations           *[]time.Duration
+	Time                *time.Time
+	MustTime            *time.Time
+	Times               *[]time.Time
+	MustTimes           *[]time.Time
+	URL                 **url.URL
+	MustURL             **url.URL
+	URLs                *[]*url.URL
+	MustURLs            *[]*url.URL
+	IPAddr              *netip.Addr
+	MustIPAddr          *netip.Addr
+	IPAddrs             *[]netip.Addr
+	MustIPAddrs         *[]netip.Addr
+	IPAddrPort          *netip.AddrPort
+	MustIPAddrPort      *netip.AddrPort
+	IPAddrPorts         *[]netip.AddrPort
+	MustIPAddrPorts     *[]netip.AddrPort
+	MAC                 *net.HardwareAddr
+	MustMAC             *net.HardwareAddr
+	MACs                *[]net.HardwareAddr
+	MustMACs            *[]net.HardwareAddr
+	IntRanges           *[]int
+	BoolOrDuration      *flagr.Toggle
+	FeatureSet          *map[string]bool
+	PrefixSet           *[]netip.Prefix
+	KeyValues           *[]flagr.KeyValue
+	StringValidated     *string
+	StringsValidated    *[]string
+	SemVer              *flagr.Version
+	SemVers             *[]flagr.Version
+	TimeOfDay           *flagr.Clock
+	TimesOfDay          *[]flagr.Clock
+	EnumSet             *[]string
+	EnumSetDedupe       *[]string
+	StringOrFile        *string
+	Rate                *float64
+	Seconds             *time.Duration
+	SecondsList         *[]time.Duration
+	Schema              *[]flagr.Column
+	SI                  *float64
+	PortRanges          *[]flagr.PortRange
+	Distribution        *[]float64
+	Cron                *flagr.CronExpr
+	Tristate            *flagr.TriState
+	Indirect            *flagr.IndirectValue
+	Quantity            *flagr.QuantityValue
+	Ordering            *[]string
+	IntStep             *int
+	UintStep            *uint
+	IntBase             *int
+	UintBase            *uint
+	DurationDefaultUnit *time.Duration
+	Tuple3              *flagr.Triple[int, int, string]
+	Fields              *map[string]any
 }
 
 type Defaults struct {
-	Int             int
-	Ints            []int
-	Int8            int8
-	Int8s           []int8
-	Int16           int16
-	Int16s          []int16
-	Int32           int32
-	Int32s          []int32
-	Int64           int64
-	Int64s          []int64
-	Uint            uint
-	Uints           []uint
-	Uint8           uint8
-	Uint8s          []uint8
-	Uint16          uint16
-	Uint16s         []uint16
-	Uint32          uint32
-	Uint32s         []uint32
-	Uint64          uint64
-	Uint64s         []uint64
-	Float32         float32
-	Float32s        []float32
-	Float64         float64
-	Float64s        []float64
-	Complex64       complex64
-	Complex64s      []complex64
-	Complex128      complex128
-	Complex128s     []complex128
-	Bool            bool
-	Bools           []bool
-	String          string
-	Strings         []string
-	Duration        time.Duration
-	Durations       []time.Duration
-	Time            time.Time
-	MustTime        string
-	Times           []time.Time
-	MustTimes       []string
-	URL             *url.URL
-	MustURL         string
-	URLs            []*url.URL
-	MustURLs        []string
-	IPAddr          netip.Addr
-	MustIPAddr      string
-	IPAddrs         []netip.Addr
-	MustIPAddrs     []string
-	IPAddrPort      netip.AddrPort
-	MustIPAddrPort  string
-	IPAddrPorts     []netip.AddrPort
-	MustIPAddrPorts []string
+	Int                 int
+	Ints                []int
+	Int8                int8
+	Int8s               []int8
+	Int16               int16
+	Int16s              []int16
+	Int32               int32
+	Int32s              []int32
+	Int64               int64
+	Int64s              []int64
+	Uint                uint
+	Uints               []uint
+	Uint8               uint8
+	Uint8s              []uint8
+	Uint16              uint16
+	Uint16s             []uint16
+	Uint32              uint32
+	Uint32s             []uint32
+	Uint64              uint64
+	Uint64s             []uint64
+	Float32             float32
+	Float32s            []float32
+	Float64             float64
+	Float64s            []float64
+	Complex64           complex64
+	Complex64s          []complex64
+	Complex128          complex128
+	Complex128s         []complex128
+	Bool                bool
+	Bools               []bool
+	String              string
+	Strings             []string
+	Duration            time.Duration
+	Durations           []time.Duration
+	Time                time.Time
+	MustTime            string
+	Times               []time.Time
+	MustTimes           []string
+	URL                 *url.URL
+	MustURL             string
+	URLs                []*url.URL
+	MustURLs            []string
+	IPAddr              netip.Addr
+	MustIPAddr          string
+	IPAddrs             []netip.Addr
+	MustIPAddrs         []string
+	IPAddrPort          netip.AddrPort
+	MustIPAddrPort      string
+	IPAddrPorts         []netip.AddrPort
+	MustIPAddrPorts     []string
+	MAC                 net.HardwareAddr
+	MustMAC             string
+	MACs                []net.HardwareAddr
+	MustMACs            []string
+	IntRanges           []int
+	BoolOrDuration      flagr.Toggle
+	FeatureSet          []string
+	PrefixSet           []netip.Prefix
+	KeyValues           []string
+	StringValidated     string
+	StringsValidated    []string
+	SemVer              string
+	SemVers             []string
+	TimeOfDay           string
+	TimesOfDay          []string
+	EnumSet             []string
+	EnumSetDedupe       []string
+	StringOrFile        string
+	Rate                float64
+	Seconds             time.Duration
+	SecondsList         []time.Duration
+	Schema              []string
+	SI                  float64
+	PortRanges          []string
+	Distribution        []float64
+	Cron                string
+	Tristate            flagr.TriState
+	Indirect            string
+	Quantity            string
+	Ordering            []string
+	IntStep             int
+	UintStep            uint
+	IntBase             int
+	UintBase            uint
+	DurationDefaultUnit time.Duration
+	Tuple3              string
+	Fields              string
 }
 
 func Make(s *flagr.Set, prefix string) (Flags, Defaults) {
 	defaults := Defaults{
-		Int:             42,
-		Ints:            []int{42, 24},
-		Int8:            42,
-		Int8s:           []int8{42, 24},
-		Int16:           42,
-		Int16s:          []int16{42, 24},
-		Int32:           42,
-		Int32s:          []int32{42, 24},
-		Int64:           42,
-		Int64s:          []int64{42, 24},
-		Uint:            42,
-		Uints:           []uint{42, 24},
-		Uint8:           42,
-		Uint8s:          []uint8{42, 24},
-		Uint16:          42,
-		Uint16s:         []uint16{42, 24},
-		Uint32:          42,
-		Uint32s:         []uint32{42, 24},
-		Uint64:          42,
-		Uint64s:         []uint64{42, 24},
-		Float32:         4.2,
-		Float32s:        []float32{4.2, 2.4},
-		Float64:         4.2,
-		Float64s:        []float64{4.2, 2.4},
-		Complex64:       42i,
-		Complex64s:      []complex64{42i, 24i},
-		Complex128:      42i,
-		Complex128s:     []complex128{42i, 24i},
-		Bool:            true,
-		Bools:           []bool{true, false},
-		String:          "asd",
-		Strings:         []string{"asd", "dsa"},
-		Duration:        42 * time.Second,
-		Durations:       []time.Duration{42 * time.Second, 24 * time.Second},
-		Time:            MustTime("4242-02-24"),
-		MustTime:        "4242-02-24",
-		Times:           []time.Time{MustTime("4242-02-24"), MustTime("2000-02-24")},
-		MustTimes:       []string{"4242-02-24", "2000-02-24"},
-		URL:             MustURL("https://go.dev"),
-		MustURL:         "https://go.dev",
-		URLs:            []*url.URL{MustURL("https://go.dev"), MustURL("https://go.dev/tour/")},
-		MustURLs:        []string{"https://go.dev", "https://go.dev/tour/"},
-		IPAddr:          netip.MustParseAddr("127.0.0.1"),
-		MustIPAddr:      "127.0.0.1",
-		IPAddrs:         []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2")},
-		MustIPAddrs:     []string{"127.0.0.1", "127.0.0.2"},
-		IPAddrPort:      netip.MustParseAddrPort("127.0.0.1:80"),
-		MustIPAddrPort:  "127.0.0.1:80",
-		IPAddrPorts:     []netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:80"), netip.MustParseAddrPort("127.0.0.1:81")},
-		MustIPAddrPorts: []string{"127.0.0.1:80", "127.0.0.1:81"},
+		Int:                 42,
+		Ints:                []int{42, 24},
+		Int8:                42,
+		Int8s:               []int8{42, 24},
+		Int16:               42,
+		Int16s:              []int16{42, 24},
+		Int32:               42,
+		Int32s:              []int32{42, 24},
+		Int64:               42,
+		Int64s:              []int64{42, 24},
+		Uint:                42,
+		Uints:               []uint{42, 24},
+		Uint8:               42,
+		Uint8s:              []uint8{42, 24},
+		Uint16:              42,
+		Uint16s:             []uint16{42, 24},
+		Uint32:              42,
+		Uint32s:             []uint32{42, 24},
+		Uint64:              42,
+		Uint64s:             []uint64{42, 24},
+		Float32:             4.2,
+		Float32s:            []float32{4.2, 2.4},
+		Float64:             4.2,
+		Float64s:            []float64{4.2, 2.4},
+		Complex64:           42i,
+		Complex64s:          []complex64{42i, 24i},
+		Complex128:          42i,
+		Complex128s:         []complex128{42i, 24i},
+		Bool:                true,
+		Bools:               []bool{true, false},
+		String:              "asd",
+		Strings:             []string{"asd", "dsa"},
+		Duration:            42 * time.Second,
+		Durations:           []time.Duration{42 * time.Second, 24 * time.Second},
+		Time:                MustTime("4242-02-24"),
+		MustTime:            "4242-02-24",
+		Times:               []time.Time{MustTime("4242-02-24"), MustTime("2000-02-24")},
+		MustTimes:           []string{"4242-02-24", "2000-02-24"},
+		URL:                 MustURL("https://go.dev"),
+		MustURL:             "https://go.dev",
+		URLs:                []*url.URL{MustURL("https://go.dev"), MustURL("https://go.dev/tour/")},
+		MustURLs:            []string{"https://go.dev", "https://go.dev/tour/"},
+		IPAddr:              netip.MustParseAddr("127.0.0.1"),
+		MustIPAddr:          "127.0.0.1",
+		IPAddrs:             []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2")},
+		MustIPAddrs:         []string{"127.0.0.1", "127.0.0.2"},
+		IPAddrPort:          netip.MustParseAddrPort("127.0.0.1:80"),
+		MustIPAddrPort:      "127.0.0.1:80",
+		IPAddrPorts:         []netip.AddrPort{netip.MustParseAddrPort("127.0.0.1:80"), netip.MustParseAddrPort("127.0.0.1:81")},
+		MustIPAddrPorts:     []string{"127.0.0.1:80", "127.0.0.1:81"},
+		MAC:                 MustMAC("aa:bb:cc:dd:ee:ff"),
+		MustMAC:             "aa:bb:cc:dd:ee:ff",
+		MACs:                []net.HardwareAddr{MustMAC("aa:bb:cc:dd:ee:ff"), MustMAC("aa:bb:cc:dd:ee:fe")},
+		MustMACs:            []string{"aa:bb:cc:dd:ee:ff", "aa:bb:cc:dd:ee:fe"},
+		IntRanges:           []int{42, 24},
+		BoolOrDuration:      flagr.Toggle{Enabled: true, TTL: 42 * time.Second},
+		FeatureSet:          []string{"asd", "dsa"},
+		PrefixSet:           []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("10.0.0.0/8")},
+		KeyValues:           []string{"asd=1", "dsa=2"},
+		StringValidated:     "asd",
+		StringsValidated:    []string{"asd", "dsa"},
+		SemVer:              "4.2.0",
+		SemVers:             []string{"4.2.0", "2.4.0"},
+		TimeOfDay:           "04:02",
+		TimesOfDay:          []string{"04:02", "02:04"},
+		EnumSet:             []string{"asd", "dsa"},
+		EnumSetDedupe:       []string{"asd", "dsa"},
+		StringOrFile:        "asd",
+		Rate:                4.2,
+		Seconds:             42 * time.Second,
+		SecondsList:         []time.Duration{42 * time.Second, 24 * time.Second},
+		Schema:              []string{"asd=int,dsa=string"},
+		SI:                  4.2,
+		PortRanges:          []string{"42-4242", "24"},
+		Distribution:        []float64{0.5, 0.5},
+		Cron:                "*/42 * * * *",
+		Tristate:            flagr.Always,
+		Indirect:            "asd",
+		Quantity:            "500m",
+		Ordering:            []string{"asd", "dsa"},
+		IntStep:             42,
+		UintStep:            42,
+		IntBase:             42,
+		UintBase:            42,
+		DurationDefaultUnit: 42 * time.Second,
+		Tuple3:              "4,2,asd",
+		Fields:              "asd=4",
 	}
 
 	var vals Flags
@@ -221,6 +335,43 @@ func Make(s *flagr.Set, prefix string) (Flags, Defaults) {
 	vals.MustIPAddrPort = flagr.Add(s, prefix+"a47", flagr.MustIPAddrPort(defaults.MustIPAddrPort), "usage for a47")
 	vals.IPAddrPorts = flagr.Add(s, prefix+"a48", flagr.IPAddrPorts(defaults.IPAddrPorts...), "usage for a48")
 	vals.MustIPAddrPorts = flagr.Add(s, prefix+"a49", flagr.MustIPAddrPorts(defaults.MustIPAddrPorts...), "usage for a49")
+	vals.MAC = flagr.Add(s, prefix+"a50", flagr.MAC(defaults.MAC), "usage for a50")
+	vals.MustMAC = flagr.Add(s, prefix+"a51", flagr.MustMAC(defaults.MustMAC), "usage for a51")
+	vals.MACs = flagr.Add(s, prefix+"a52", flagr.MACs(defaults.MACs...), "usage for a52")
+	vals.MustMACs = flagr.Add(s, prefix+"a53", flagr.MustMACs(defaults.MustMACs...), "usage for a53")
+	vals.IntRanges = flagr.Add(s, prefix+"a54", flagr.IntRanges(defaults.IntRanges...), "usage for a54")
+	vals.BoolOrDuration = flagr.Add(s, prefix+"a55", flagr.BoolOrDuration(defaults.BoolOrDuration), "usage for a55")
+	vals.FeatureSet = flagr.Add(s, prefix+"a56", flagr.FeatureSet(defaults.FeatureSet), "usage for a56")
+	vals.PrefixSet = flagr.Add(s, prefix+"a57", flagr.PrefixSet(defaults.PrefixSet), "usage for a57")
+	vals.KeyValues = flagr.Add(s, prefix+"a58", flagr.KeyValues(defaults.KeyValues...), "usage for a58")
+	vals.StringValidated = flagr.Add(s, prefix+"a59", flagr.StringValidated(defaults.StringValidated, flagr.NonEmpty(), flagr.MaxLen(8)), "usage for a59")
+	vals.StringsValidated = flagr.Add(s, prefix+"a60", flagr.StringsValidated(defaults.StringsValidated, flagr.NonEmpty(), flagr.MaxLen(8)), "usage for a60")
+	vals.SemVer = flagr.Add(s, prefix+"a61", flagr.SemVer(defaults.SemVer), "usage for a61")
+	vals.SemVers = flagr.Add(s, prefix+"a62", flagr.SemVers(defaults.SemVers...), "usage for a62")
+	vals.TimeOfDay = flagr.Add(s, prefix+"a63", flagr.TimeOfDay(defaults.TimeOfDay), "usage for a63")
+	vals.TimesOfDay = flagr.Add(s, prefix+"a64", flagr.TimesOfDay(defaults.TimesOfDay...), "usage for a64")
+	vals.EnumSet = flagr.Add(s, prefix+"a65", flagr.EnumSet(",", []string{"asd", "dsa", "qwe"}, defaults.EnumSet...), "usage for a65")
+	vals.EnumSetDedupe = flagr.Add(s, prefix+"a66", flagr.EnumSetDedupe(",", []string{"asd", "dsa", "qwe"}, defaults.EnumSetDedupe...), "usage for a66")
+	vals.StringOrFile = flagr.Add(s, prefix+"a67", flagr.StringOrFile(defaults.StringOrFile), "usage for a67")
+	vals.Rate = flagr.Add(s, prefix+"a68", flagr.Rate(defaults.Rate), "usage for a68")
+	vals.Seconds = flagr.Add(s, prefix+"a69", flagr.Seconds(defaults.Seconds), "usage for a69")
+	vals.SecondsList = flagr.Add(s, prefix+"a70", flagr.SecondsList(defaults.SecondsList...), "usage for a70")
+	vals.Schema = flagr.Add(s, prefix+"a71", flagr.Schema(defaults.Schema), "usage for a71")
+	vals.SI = flagr.Add(s, prefix+"a72", flagr.SI(defaults.SI), "usage for a72")
+	vals.PortRanges = flagr.Add(s, prefix+"a73", flagr.PortRanges(defaults.PortRanges...), "usage for a73")
+	vals.Distribution = flagr.Add(s, prefix+"a74", flagr.Distribution(0.001, defaults.Distribution...), "usage for a74")
+	vals.Cron = flagr.Add(s, prefix+"a75", flagr.Cron(defaults.Cron), "usage for a75")
+	vals.Tristate = flagr.Add(s, prefix+"a76", flagr.Tristate(defaults.Tristate), "usage for a76")
+	vals.Indirect = flagr.Add(s, prefix+"a77", flagr.Indirect(defaults.Indirect, map[string]func(string) (string, error){"upper": func(s string) (string, error) { return strings.ToUpper(s), nil }}), "usage for a77")
+	vals.Quantity = flagr.Add(s, prefix+"a78", flagr.Quantity(defaults.Quantity), "usage for a78")
+	vals.Ordering = flagr.Add(s, prefix+"a79", flagr.Ordering([]string{"asd", "dsa", "qwe"}, defaults.Ordering...), "usage for a79")
+	vals.IntStep = flagr.Add(s, prefix+"a80", flagr.IntStep(defaults.IntStep, 6), "usage for a80")
+	vals.UintStep = flagr.Add(s, prefix+"a81", flagr.UintStep(defaults.UintStep, 6), "usage for a81")
+	vals.IntBase = flagr.Add(s, prefix+"a82", flagr.IntBase(defaults.IntBase, 16), "usage for a82")
+	vals.UintBase = flagr.Add(s, prefix+"a83", flagr.UintBase(defaults.UintBase, 8), "usage for a83")
+	vals.DurationDefaultUnit = flagr.Add(s, prefix+"a84", flagr.DurationDefaultUnit(time.Second, defaults.DurationDefaultUnit), "usage for a84")
+	vals.Tuple3 = flagr.Add(s, prefix+"a85", flagr.Tuple3(",", strconv.Atoi, strconv.Atoi, func(s string) (string, error) { return s, nil }, defaults.Tuple3), "usage for a85")
+	vals.Fields = flagr.Add(s, prefix+"a86", flagr.Fields(",").Required("asd", func(s string) (any, error) { return strconv.Atoi(s) }).Optional("dsa", func(s string) (any, error) { return s, nil }).Getter(defaults.Fields), "usage for a86")
 	return vals, defaults
 }
 
@@ -232,6 +383,14 @@ func MustTime(t string) time.Time {
 	return v
 }
 
+func MustMAC(t string) net.HardwareAddr {
+	v, err := net.ParseMAC(t)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 func MustURL(t string) *url.URL {
 	v, err := url.Parse(t)
 	if err != nil {
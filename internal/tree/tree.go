@@ -0,0 +1,80 @@
+// Package tree contains helpers to walk the values produced by decoding config
+// documents, such as json objects, and convert them to flag values.
+package tree
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Find walks root following path and returns the value found, if any.
+func Find(root map[string]any, path []string) (reflect.Value, bool) {
+	rv := unwrap(reflect.ValueOf(root))
+	for _, segment := range path {
+		if rv.Kind() != reflect.Map {
+			return reflect.Value{}, false
+		}
+		rv = unwrap(rv.MapIndex(reflect.ValueOf(segment)))
+		if !rv.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+	return rv, true
+}
+
+func unwrap(rv reflect.Value) reflect.Value {
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return rv.Elem()
+	default:
+		return rv
+	}
+}
+
+// Stringify converts v to strings and appends them to values, slices produce
+// one string per element. Only primitive values and slices of them are supported.
+func Stringify(v reflect.Value, values *[]string) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		*values = append(*values, strconv.FormatBool(v.Bool()))
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*values = append(*values, strconv.FormatInt(v.Int(), 10))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		*values = append(*values, strconv.FormatUint(v.Uint(), 10))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		*values = append(*values, strconv.FormatFloat(v.Float(), 'f', -1, 64))
+		return nil
+
+	case reflect.Interface, reflect.Pointer:
+		return Stringify(v.Elem(), values)
+
+	case reflect.Slice:
+		len := v.Len()
+		for i := 0; i < len; i++ {
+			if err := Stringify(v.Index(i), values); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		*values = append(*values, v.String())
+		return nil
+
+	case reflect.Map, reflect.Struct:
+		return fmt.Errorf("cannot use an object as a flag value, expected a scalar or an array of scalars")
+
+	case reflect.Invalid:
+		return fmt.Errorf("cannot use null as a flag value")
+
+	default:
+		return fmt.Errorf("unsupported type %q", v.Type().String())
+	}
+}